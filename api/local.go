@@ -0,0 +1,12 @@
+package handler
+
+import (
+	"net/http"
+
+	"Pines/service"
+)
+
+// LocalHandler 本地文件系统存储句柄 仅在独立运行模式下可用
+func LocalHandler(w http.ResponseWriter, r *http.Request) {
+	service.Handle(w, r, "Local")
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetUploadAPI(t *testing.T) {
+	var tests = []struct {
+		name   string
+		public bool   //是否开启PublicUpload
+		target string //请求地址
+		want   string //返回的UToken
+	}{
+		{"no token", false, "/api/misc", ""},
+		{"wrong token", false, "/api/misc?token=bad", ""},
+		{"token", false, "/api/misc?token=admin", "up"},
+		{"public upload", true, "/api/misc", "up"},
+	}
+	for _, test := range tests {
+		cleanup := useTestConfig(t, fmt.Sprintf("Default: Local\nToken: admin\nUToken: up\nPublicUpload: %t\n", test.public))
+		var w = httptest.NewRecorder()
+		GetUploadAPI(w, httptest.NewRequest(http.MethodGet, test.target, nil))
+		var resp struct {
+			Utoken string `json:"utoken"`
+			URL    string `json:"url"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: decode %q: %v", test.name, w.Body.String(), err)
+		}
+		if resp.Utoken != test.want || resp.URL != "Local" {
+			t.Errorf("%s: utoken = %q, url = %q, want %q, Local", test.name, resp.Utoken, resp.URL, test.want)
+		}
+		cleanup()
+	}
+}
+
+// useTestConfig 在临时目录中写入config.yaml并切换工作目录 返回清理函数
+func useTestConfig(t *testing.T, content string) func() {
+	dir, err := ioutil.TempDir("", "pines-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "config.yaml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		_ = os.Chdir(wd)
+		_ = os.RemoveAll(dir)
+	}
+}
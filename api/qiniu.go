@@ -0,0 +1,12 @@
+package handler
+
+import (
+	"net/http"
+
+	"Pines/service"
+)
+
+// QiniuHandler 七牛云Kodo服务句柄
+func QiniuHandler(w http.ResponseWriter, r *http.Request) {
+	service.Handle(w, r, "Qiniu")
+}
@@ -0,0 +1,12 @@
+package handler
+
+import (
+	"net/http"
+
+	"Pines/service"
+)
+
+// S3Handler Amazon S3及兼容S3协议的存储服务句柄
+func S3Handler(w http.ResponseWriter, r *http.Request) {
+	service.Handle(w, r, "S3")
+}
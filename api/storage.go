@@ -0,0 +1,13 @@
+package handler
+
+import (
+	"net/http"
+
+	"Pines/service"
+)
+
+// StorageHandler 按名称访问存储服务或配置文件中命名的存储目标 名称由storage参数指定
+// /api/storage/<名称> 会被改写为 /api/storage?storage=<名称>
+func StorageHandler(w http.ResponseWriter, r *http.Request) {
+	service.Handle(w, r, r.URL.Query().Get("storage"))
+}
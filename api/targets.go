@@ -0,0 +1,12 @@
+package handler
+
+import (
+	"net/http"
+
+	"Pines/service"
+)
+
+// TargetsHandler 列出可以切换的存储目标
+func TargetsHandler(w http.ResponseWriter, r *http.Request) {
+	service.Targets(w, r)
+}
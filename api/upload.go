@@ -0,0 +1,12 @@
+package handler
+
+import (
+	"net/http"
+
+	"Pines/service"
+)
+
+// UploadHandler 快捷上传句柄 供外部脚本通过UToken上传文件到默认存储服务
+func UploadHandler(w http.ResponseWriter, r *http.Request) {
+	service.QuickUpload(w, r)
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Cos 腾讯云Cos服务
+type Cos struct {
+	SecretID   string `yaml:"SecretID"`   //API密钥ID
+	SecretKey  string `yaml:"SecretKey"`  //API密钥私钥
+	Bucket     string `yaml:"Bucket"`     //存储桶名称 规则 test-1234567889
+	Region     string `yaml:"Region"`     //存储桶所属地域 规则 ap-nanjing
+	Domain     string `yaml:"Domain"`     //自定义域名
+	APIAddress string `yaml:"APIAddress"` //API地址(访问域名) 在存储桶列表->配置管理->基础配置中可见 规则 https://<bucket>.cos.<region>.myqcloud.com
+}
+
+// Oss 阿里云Oss服务
+type Oss struct {
+	Ak       string `yaml:"Ak"`       //AccessKey ID
+	Sk       string `yaml:"Sk"`       //Access Key Secret
+	Bucket   string `yaml:"Bucket"`   //Bucket
+	Endpoint string `yaml:"Endpoint"` //外网访问地域节点(非Bucket域名)
+	Domain   string `yaml:"Domain"`   //自定义域名(Bucket域名或自定义)
+}
+
+// Ups 又拍云Ups服务
+type Ups struct {
+	Bucket   string `yaml:"Bucket"`   //服务名称
+	Operator string `yaml:"Operator"` //授权的操作员名称
+	Password string `yaml:"Password"` //授权的操作员密码
+	Domain   string `yaml:"Domain"`   //加速域名
+	Secret   string `yaml:"Secret"`   //Token防盗链密钥 用于生成临时下载地址
+}
+
+// S3 Amazon S3及兼容S3协议的存储服务
+type S3 struct {
+	Endpoint  string `yaml:"Endpoint"`  //服务地址(不含协议) 规则 s3.amazonaws.com 或 127.0.0.1:9000
+	Region    string `yaml:"Region"`    //存储桶所属地域 规则 us-east-1
+	AccessKey string `yaml:"AccessKey"` //Access Key ID
+	SecretKey string `yaml:"SecretKey"` //Secret Access Key
+	Bucket    string `yaml:"Bucket"`    //存储桶名称
+	UseSSL    bool   `yaml:"UseSSL"`    //是否使用https访问服务
+	PathStyle bool   `yaml:"PathStyle"` //是否使用路径风格访问存储桶 MinIO等自建服务通常需要开启
+	Domain    string `yaml:"Domain"`    //自定义域名
+}
+
+// Qiniu 七牛云Kodo服务
+type Qiniu struct {
+	AccessKey string `yaml:"AccessKey"` //AccessKey
+	SecretKey string `yaml:"SecretKey"` //SecretKey
+	Bucket    string `yaml:"Bucket"`    //存储空间名称
+	Region    string `yaml:"Region"`    //存储区域 规则 z0(华东) z1(华北) z2(华南) na0(北美) as0(东南亚)
+	Domain    string `yaml:"Domain"`    //绑定的访问域名
+}
+
+// Local 本地文件系统存储
+type Local struct {
+	Root   string `yaml:"Root"`   //存储目录 默认为 data
+	Domain string `yaml:"Domain"` //访问域名 默认为 /local/ 由Pines提供访问
+}
+
+// Mirror 上传与删除操作的镜像复制
+type Mirror struct {
+	Targets map[string][]string `yaml:"Targets"` //主存储服务对应的备份存储服务 规则 Cos: [Oss, Ups]
+	Retry   int                 `yaml:"Retry"`   //复制失败后自动重试的次数 默认为5
+	Queue   string              `yaml:"Queue"`   //复制队列的保存文件 默认为系统临时目录下的 pines-mirror.json
+}
+
+// Target 命名的存储目标 同一种存储服务可以配置多个存储桶
+// 只需要填写Type对应的存储服务配置
+type Target struct {
+	Name  string `yaml:"Name"` //目标名称 不能包含 / 不能重复 也不能与存储服务类型重名
+	Type  string `yaml:"Type"` //存储服务类型 [Ups/Cos/Oss/S3/Qiniu/Local]
+	Cos   Cos    `yaml:"Cos"`
+	Oss   Oss    `yaml:"Oss"`
+	Ups   Ups    `yaml:"Ups"`
+	S3    S3     `yaml:"S3"`
+	Qiniu Qiniu  `yaml:"Qiniu"`
+	Local Local  `yaml:"Local"`
+}
+
+// Config 配置文件解析
+type Config struct {
+	Port         string   `yaml:"Port"`
+	Default      string   `yaml:"Default"`
+	Token        string   `yaml:"Token"`
+	UToken       string   `yaml:"UToken"`
+	PublicUpload bool     `yaml:"PublicUpload"` //是否公开上传页面 开启后 /api/misc 无需Token即返回UToken
+	Cos          Cos      `yaml:"Cos"`
+	Oss          Oss      `yaml:"Oss"`
+	Ups          Ups      `yaml:"Ups"`
+	S3           S3       `yaml:"S3"`
+	Qiniu        Qiniu    `yaml:"Qiniu"`
+	Local        Local    `yaml:"Local"`
+	Mirror       Mirror   `yaml:"Mirror"`
+	Targets      []Target `yaml:"Targets"`
+}
+
+// Target 查找名称为name的存储目标
+func (c *Config) Target(name string) (*Target, bool) {
+	for i := range c.Targets {
+		if c.Targets[i].Name == name {
+			return &c.Targets[i], true
+		}
+	}
+	return nil, false
+}
+
+// CheckTargets 检查命名的存储目标 名称不能为空、包含 /、重复或与存储服务类型重名 Type必须为types中的存储服务类型
+func (c *Config) CheckTargets(types []string) error {
+	var known = make(map[string]bool, len(types))
+	for _, t := range types {
+		known[t] = true
+	}
+	var names = make(map[string]bool, len(c.Targets))
+	for _, target := range c.Targets {
+		switch {
+		case target.Name == "":
+			return errors.New("targets: empty target name")
+		case strings.Contains(target.Name, "/"):
+			return fmt.Errorf("targets: name %q contains /", target.Name)
+		case known[target.Name]:
+			return fmt.Errorf("targets: name %q conflicts with storage type", target.Name)
+		case names[target.Name]:
+			return fmt.Errorf("targets: duplicate name %q", target.Name)
+		case !known[target.Type]:
+			return fmt.Errorf("targets: unknown type %q for %q", target.Type, target.Name)
+		}
+		names[target.Name] = true
+	}
+	return nil
+}
+
+// WithTarget 返回以target的配置替换对应存储服务配置后的副本 副本中不再包含命名的存储目标
+func (c *Config) WithTarget(target *Target) *Config {
+	var conf = *c
+	conf.Targets = nil
+	switch target.Type {
+	case "Cos":
+		conf.Cos = target.Cos
+	case "Oss":
+		conf.Oss = target.Oss
+	case "Ups":
+		conf.Ups = target.Ups
+	case "S3":
+		conf.S3 = target.S3
+	case "Qiniu":
+		conf.Qiniu = target.Qiniu
+	case "Local":
+		conf.Local = target.Local
+	}
+	return &conf
+}
+
+// GetConfig 调用该方法会实例化conf 项目运行会读取一次配置文件 确保不会有多余的读取损耗
+func GetConfig() *Config {
+	var config = new(Config)
+	yamlFile, err := ioutil.ReadFile("config.yaml")
+	if err != nil {
+		panic(err)
+	}
+	err = yaml.Unmarshal(yamlFile, config)
+	if err != nil {
+		//读取配置文件失败,停止执行
+		panic("read config file error:" + err.Error())
+	}
+	return config
+}
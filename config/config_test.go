@@ -0,0 +1,26 @@
+package config
+
+import "testing"
+
+func TestCheckTargets(t *testing.T) {
+	var types = []string{"Cos", "Local", "Oss"}
+	var tests = []struct {
+		targets []Target
+		ok      bool
+	}{
+		{nil, true},
+		{[]Target{{Name: "blog", Type: "Cos"}, {Name: "backup", Type: "Oss"}}, true},
+		{[]Target{{Name: "", Type: "Cos"}}, false},
+		{[]Target{{Name: "a/b", Type: "Local"}}, false},
+		{[]Target{{Name: "Cos", Type: "Oss"}}, false},
+		{[]Target{{Name: "blog", Type: "Cos"}, {Name: "blog", Type: "Oss"}}, false},
+		{[]Target{{Name: "blog", Type: "Kodo"}}, false},
+		{[]Target{{Name: "blog"}}, false},
+	}
+	for i, test := range tests {
+		var conf = &Config{Targets: test.targets}
+		if err := conf.CheckTargets(types); (err == nil) != test.ok {
+			t.Errorf("case %d: CheckTargets = %v, want ok %v", i, err, test.ok)
+		}
+	}
+}
@@ -6,6 +6,7 @@ require (
 	github.com/aliyun/aliyun-oss-go-sdk v2.0.8+incompatible
 	github.com/baiyubin/aliyun-sts-go-sdk v0.0.0-20180326062324-cfa1a18b161f // indirect
 	github.com/julienschmidt/httprouter v1.3.0
+	github.com/minio/minio-go/v6 v6.0.55
 	github.com/satori/go.uuid v1.2.0 // indirect
 	github.com/tencentyun/cos-go-sdk-v5 v0.7.4
 	github.com/upyun/go-sdk v2.1.0+incompatible
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	handler "Pines/api"
+	"Pines/config"
+	"Pines/service"
+	"Pines/storage"
+)
+
+// defaultPort 未配置Port时使用的服务端口
+const defaultPort = ":7125"
+
+// methods 接口允许的请求方式 与跨域响应头保持一致
+var methods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPatch,
+	http.MethodPut,
+	http.MethodOptions,
+}
+
+// routes 与 now.json 中的路由保持一致 本地存储仅在独立运行模式下可用
+var routes = map[string]http.HandlerFunc{
+	"/api/cos":     handler.CosHandler,
+	"/api/oss":     handler.OssHandler,
+	"/api/ups":     handler.UpsHandler,
+	"/api/s3":      handler.S3Handler,
+	"/api/qiniu":   handler.QiniuHandler,
+	"/api/local":   handler.LocalHandler,
+	"/api/storage": handler.StorageHandler,
+	"/api/targets": handler.TargetsHandler,
+	"/api/login":   handler.Login,
+	"/api/misc":    handler.GetUploadAPI,
+	"/api/upload":  handler.UploadHandler,
+}
+
+// NewRouter 挂载所有接口 其余请求交给 dist 目录下的前端页面
+func NewRouter(conf *config.Config) *httprouter.Router {
+	router := httprouter.New()
+	for path, handle := range routes {
+		for _, method := range methods {
+			router.HandlerFunc(method, path, handle)
+		}
+	}
+	//命名的存储目标 与 now.json 一致改写为 /api/storage?storage=<名称>
+	for _, method := range methods {
+		router.Handle(method, "/api/storage/:name", storageRoute)
+	}
+	//本地存储的文件访问 包括命名的本地存储目标 未配置本地存储时不提供
+	if local := storage.NewLocalServer(conf); local != nil {
+		router.Handler(http.MethodGet, storage.LocalRoute+"*filepath", local)
+	}
+	router.NotFound = http.FileServer(http.Dir("dist"))
+	return router
+}
+
+// storageRoute 将 /api/storage/<名称> 转为 storage 参数 前端按 /api/<名称> 拼接接口地址时也能访问命名的存储目标
+func storageRoute(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var query = r.URL.Query()
+	query.Set("storage", ps.ByName("name"))
+	r.URL.RawQuery = query.Encode()
+	handler.StorageHandler(w, r)
+}
+
+func main() {
+	var conf = config.GetConfig()
+	if err := conf.CheckTargets(storage.Drivers()); err != nil {
+		log.Fatal(err)
+	}
+	var port = conf.Port
+	if port == "" {
+		port = defaultPort
+	}
+	//镜像复制由后台执行 失败的任务定时重试
+	service.StartMirror()
+	log.Printf("Pines is running at %s", port)
+	log.Fatal(http.ListenAndServe(port, NewRouter(conf)))
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"Pines/config"
+	"Pines/storage"
+)
+
+// defaultExtractMax 解压时单个文件的默认大小上限
+const defaultExtractMax = 512 << 20
+
+var (
+	// errUnsafePath 压缩包中的路径试图跳出目标目录
+	errUnsafePath = errors.New("unsafe path")
+	// errTooLarge 文件超过大小上限
+	errTooLarge = errors.New("file too large")
+	// errEntryType 压缩包中不支持的文件类型 如符号链接
+	errEntryType = errors.New("unsupported entry type")
+)
+
+// zipDir 将path目录下的所有文件打包为ZIP下载 边读取边压缩 不会写入磁盘
+// 携带 max 参数时 目录总大小超过max字节将拒绝打包
+func zipDir(w http.ResponseWriter, r *http.Request, store storage.Storage) {
+	var query = r.URL.Query()
+	var prefix = query.Get("path")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		WriteJSON(w, &Response{
+			Code:    500,
+			Message: "ErrorZip:path must be a directory",
+		})
+		return
+	}
+	var max int64
+	if value := query.Get("max"); value != "" {
+		var err error
+		if max, err = strconv.ParseInt(value, 10, 64); err != nil || max <= 0 {
+			WriteJSON(w, &Response{
+				Code:    500,
+				Message: "ErrorZip:invalid max",
+			})
+			return
+		}
+	}
+	files, err := storage.ListFiles(store, prefix)
+	if err != nil {
+		WriteError(w, "ErrorZip", err)
+		return
+	}
+	var total int64
+	for _, file := range files {
+		total += file.Bytes()
+	}
+	if max > 0 && total > max {
+		WriteJSON(w, &Response{
+			Code:    500,
+			Message: "ErrorZip:directory size " + strconv.FormatInt(total, 10) + " exceeds max",
+		})
+		return
+	}
+	var name = path.Base(strings.TrimSuffix(prefix, "/"))
+	if name == "." || name == "/" {
+		name = "archive"
+	}
+	var header = w.Header()
+	header.Set("Access-Control-Allow-Origin", "*")
+	header.Set("Access-Control-Expose-Headers", "Content-Disposition")
+	header.Set("Content-Type", "application/zip")
+	header.Set("Content-Disposition", disposition(false, name+".zip"))
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		//响应已经开始 出错时只能中断输出 客户端会得到不完整的压缩包
+		if err = writeZipEntry(archive, store, file, prefix); err != nil {
+			return
+		}
+	}
+	_ = archive.Close()
+}
+
+// writeZipEntry 将文件写入压缩包 压缩包内的路径为相对prefix的路径 不以 / 开头
+func writeZipEntry(archive *zip.Writer, store storage.Storage, file storage.ListObject, prefix string) error {
+	entry := &zip.FileHeader{
+		Name:     strings.TrimLeft(strings.TrimPrefix(file.Key(), prefix), "/"),
+		Method:   zip.Deflate,
+		Modified: file.ModTime(),
+	}
+	writer, err := archive.CreateHeader(entry)
+	if err != nil {
+		return err
+	}
+	reader, err := store.Get(file.Key())
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, err = io.Copy(writer, reader)
+	return err
+}
+
+// extract 上传.zip/.tar.gz压缩包并解压到prefix目录下 保留压缩包内的相对路径
+// 跳出目标目录的路径与超过大小上限的文件会被拒绝 携带 max 参数时使用max作为单个文件的上限
+func extract(w http.ResponseWriter, r *http.Request, store storage.Storage, name string, conf *config.Config) {
+	var max int64 = defaultExtractMax
+	if value := r.URL.Query().Get("max"); value != "" {
+		var err error
+		if max, err = strconv.ParseInt(value, 10, 64); err != nil || max <= 0 {
+			WriteJSON(w, &Response{
+				Code:    500,
+				Message: "ErrorExtract:invalid max",
+			})
+			return
+		}
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		WriteError(w, "ErrorExtract", err)
+		return
+	}
+	defer file.Close()
+	var prefix string
+	if r.MultipartForm != nil {
+		values := r.MultipartForm.Value["prefix"]
+		if len(values) > 0 {
+			prefix = values[0]
+		}
+	}
+	var result = new(storage.Result)
+	var filename = strings.ToLower(header.Filename)
+	switch {
+	case strings.HasSuffix(filename, ".zip"):
+		err = extractZip(store, file, header.Size, prefix, max, result)
+	case strings.HasSuffix(filename, ".tar.gz"), strings.HasSuffix(filename, ".tgz"):
+		err = extractTar(store, file, prefix, max, result)
+	default:
+		err = errors.New("unsupported archive format")
+	}
+	if err != nil {
+		WriteError(w, "ErrorExtract", err)
+		return
+	}
+	mirror(conf, name, "put", result.Done...)
+	writeResult(w, "ErrorExtract", result)
+}
+
+// extractZip 解压zip压缩包
+func extractZip(store storage.Storage, file io.ReaderAt, size int64, prefix string, max int64, result *storage.Result) error {
+	archive, err := zip.NewReader(file, size)
+	if err != nil {
+		return err
+	}
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		if !entry.Mode().IsRegular() {
+			rejectEntry(result, prefix+entry.Name, errEntryType)
+			continue
+		}
+		reader, err := entry.Open()
+		if err != nil {
+			rejectEntry(result, prefix+entry.Name, err)
+			continue
+		}
+		putEntry(store, reader, prefix, entry.Name, max, result)
+		_ = reader.Close()
+	}
+	return nil
+}
+
+// extractTar 解压tar.gz压缩包
+func extractTar(store storage.Storage, file io.Reader, prefix string, max int64, result *storage.Result) error {
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	archive := tar.NewReader(gz)
+	for {
+		entry, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch entry.Typeflag {
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			continue
+		case tar.TypeReg, tar.TypeRegA:
+			putEntry(store, archive, prefix, entry.Name, max, result)
+		default:
+			rejectEntry(result, prefix+entry.Name, errEntryType)
+		}
+	}
+}
+
+// putEntry 通过存储驱动的Put上传压缩包中的一个文件
+func putEntry(store storage.Storage, reader io.Reader, prefix, name string, max int64, result *storage.Result) {
+	clean, err := cleanEntry(name)
+	if err != nil {
+		rejectEntry(result, prefix+name, err)
+		return
+	}
+	if clean == "" {
+		return
+	}
+	var key = prefix + clean
+	if err = store.Put(key, &maxReader{reader: reader, remain: max}); err != nil {
+		rejectEntry(result, key, err)
+		return
+	}
+	result.Done = append(result.Done, key)
+}
+
+// rejectEntry 记录被拒绝的文件
+func rejectEntry(result *storage.Result, key string, err error) {
+	result.Failed = append(result.Failed, storage.Failure{Key: key, Error: err.Error()})
+}
+
+// cleanEntry 规范化压缩包中的路径 去掉 ./ 与多余的 / 如 tar czf site.tgz -C dist . 生成的 ./index.html
+// 绝对路径与跳出目标目录的路径返回errUnsafePath 规范化后为当前目录的条目返回空字符串
+func cleanEntry(name string) (string, error) {
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, "\\") {
+		return "", errUnsafePath
+	}
+	var clean = path.Clean(name)
+	if clean == "." {
+		return "", nil
+	}
+	if clean == ".." || strings.HasPrefix(clean, "../") || strings.HasPrefix(clean, "/") {
+		return "", errUnsafePath
+	}
+	return clean, nil
+}
+
+// maxReader 读取超过remain字节时返回errTooLarge 压缩包与响应头中记录的大小不可信
+type maxReader struct {
+	reader io.Reader
+	remain int64
+}
+
+// Read 读取内容并扣减剩余的额度
+func (m *maxReader) Read(p []byte) (int, error) {
+	if m.remain < 0 {
+		return 0, errTooLarge
+	}
+	//多读取一个字节用于判断是否超过上限 remain为最大值时不会超过
+	if m.remain < math.MaxInt64 && int64(len(p)) > m.remain+1 {
+		p = p[:m.remain+1]
+	}
+	n, err := m.reader.Read(p)
+	m.remain -= int64(n)
+	if m.remain < 0 {
+		return n, errTooLarge
+	}
+	return n, err
+}
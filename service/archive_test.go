@@ -0,0 +1,159 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"math"
+	"strings"
+	"testing"
+
+	"Pines/storage"
+)
+
+func TestCleanEntry(t *testing.T) {
+	var tests = []struct {
+		name string
+		want string
+		err  error
+	}{
+		{"a.png", "a.png", nil},
+		{"img/a.png", "img/a.png", nil},
+		{"img/..a.png", "img/..a.png", nil},
+		{"./index.html", "index.html", nil},
+		{"./css//app.css", "css/app.css", nil},
+		{"img/../a.png", "a.png", nil},
+		{".", "", nil},
+		{"./", "", nil},
+		{"", "", errUnsafePath},
+		{"/etc/passwd", "", errUnsafePath},
+		{"../a.png", "", errUnsafePath},
+		{"./../a.png", "", errUnsafePath},
+		{"img/../../a.png", "", errUnsafePath},
+		{"img/../..", "", errUnsafePath},
+		{"img\\..\\a.png", "", errUnsafePath},
+	}
+	for _, test := range tests {
+		got, err := cleanEntry(test.name)
+		if got != test.want || err != test.err {
+			t.Errorf("cleanEntry(%q) = %q, %v, want %q, %v", test.name, got, err, test.want, test.err)
+		}
+	}
+}
+
+func TestMaxReader(t *testing.T) {
+	var tests = []struct {
+		content string
+		max     int64
+		err     error
+	}{
+		{"", 0, nil},
+		{"12345", 5, nil},
+		{"12345", 10, nil},
+		{"123456", 5, errTooLarge},
+		{"1", 0, errTooLarge},
+		{"12345", math.MaxInt64, nil},
+	}
+	for _, test := range tests {
+		data, err := ioutil.ReadAll(&maxReader{reader: strings.NewReader(test.content), remain: test.max})
+		if err != test.err {
+			t.Errorf("read %q with max %d: err = %v, want %v", test.content, test.max, err, test.err)
+		}
+		if err == nil && string(data) != test.content {
+			t.Errorf("read %q with max %d = %q", test.content, test.max, data)
+		}
+	}
+}
+
+// testEntries 压缩包中的文件 包含需要规范化、跳出目录与超过大小上限的路径
+var testEntries = []struct {
+	name, content string
+}{
+	{"./index.html", "<html>"},
+	{"./css/app.css", "body{}"},
+	{"../escape.txt", "x"},
+	{"big.bin", strings.Repeat("0", 100)},
+}
+
+// checkExtract 检查解压结果
+func checkExtract(t *testing.T, store storage.Storage, result *storage.Result) {
+	var done = make(map[string]bool)
+	for _, key := range result.Done {
+		done[key] = true
+	}
+	if len(done) != 2 || !done["site/index.html"] || !done["site/css/app.css"] {
+		t.Errorf("done = %v", result.Done)
+	}
+	var failed = make(map[string]string)
+	for _, failure := range result.Failed {
+		failed[failure.Key] = failure.Error
+	}
+	if len(failed) != 2 || failed["site/../escape.txt"] != errUnsafePath.Error() || failed["site/big.bin"] != errTooLarge.Error() {
+		t.Errorf("failed = %v", result.Failed)
+	}
+	if _, err := store.Stat("escape.txt"); err == nil {
+		t.Error("entry escaped the prefix")
+	}
+	if _, err := store.Stat("site/big.bin"); err == nil {
+		t.Error("oversized entry was kept")
+	}
+}
+
+func TestExtractZip(t *testing.T) {
+	_, store, cleanup := newTestLocal(t)
+	defer cleanup()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, entry := range testEntries {
+		writer, err := archive.Create(entry.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = writer.Write([]byte(entry.content))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var result = new(storage.Result)
+	if err := extractZip(store, bytes.NewReader(buf.Bytes()), int64(buf.Len()), "site/", 64, result); err != nil {
+		t.Fatal(err)
+	}
+	checkExtract(t, store, result)
+}
+
+func TestExtractTar(t *testing.T) {
+	_, store, cleanup := newTestLocal(t)
+	defer cleanup()
+	//与 tar czf site.tgz -C dist . 的结构一致 包含 ./ 目录条目与符号链接
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	archive := tar.NewWriter(gz)
+	_ = archive.WriteHeader(&tar.Header{Name: "./", Typeflag: tar.TypeDir, Mode: 0755})
+	_ = archive.WriteHeader(&tar.Header{Name: "./css/", Typeflag: tar.TypeDir, Mode: 0755})
+	for _, entry := range testEntries {
+		if err := archive.WriteHeader(&tar.Header{Name: entry.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(entry.content))}); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = archive.Write([]byte(entry.content))
+	}
+	_ = archive.WriteHeader(&tar.Header{Name: "./link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"})
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var result = new(storage.Result)
+	if err := extractTar(store, &buf, "site/", 64, result); err != nil {
+		t.Fatal(err)
+	}
+	//符号链接被拒绝 其余与zip一致
+	var last = result.Failed[len(result.Failed)-1]
+	if last.Key != "site/./link" || last.Error != errEntryType.Error() {
+		t.Errorf("symlink failure = %+v", last)
+	}
+	result.Failed = result.Failed[:len(result.Failed)-1]
+	checkExtract(t, store, result)
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"Pines/config"
+)
+
+// TokenAuth 检测Token合法性 未配置的Token视为不可用
+func TokenAuth(token, expect string) bool {
+	if expect == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expect)) == 1
+}
+
+// RequestToken 读取请求携带的凭证 优先读取请求头 其次读取query参数
+func RequestToken(r *http.Request, name string) string {
+	if token := r.Header.Get(name); token != "" {
+		return token
+	}
+	return r.URL.Query().Get(name)
+}
+
+// Authorized 检测请求是否携带了管理Token
+func Authorized(r *http.Request, conf *config.Config) bool {
+	return TokenAuth(RequestToken(r, "token"), conf.Token)
+}
+
+// UploadAuthorized 检测请求是否携带了上传UToken 仅可用于上传操作
+func UploadAuthorized(r *http.Request, conf *config.Config) bool {
+	return TokenAuth(RequestToken(r, "utoken"), conf.UToken)
+}
+
+// Preflight 处理跨域预检请求 已处理时返回true
+func Preflight(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodOptions {
+		return false
+	}
+	Write(w, nil)
+	return true
+}
+
+// Unauthorized 输出认证失败的回应
+func Unauthorized(w http.ResponseWriter) {
+	WriteJSON(w, &Response{
+		Code:    401,
+		Message: "ErrorAuth:token error",
+	})
+}
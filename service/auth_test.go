@@ -0,0 +1,117 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	mimepart "mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testAuthConfig 认证测试使用的配置 Default为Local 另有命名的本地存储目标Other
+const testAuthConfig = `
+Default: Local
+Token: %q
+UToken: %q
+Local:
+  Root: data
+Targets:
+  - Name: Other
+    Type: Local
+    Local:
+      Root: other
+`
+
+// uploadRequest 携带一个文件的上传请求
+func uploadRequest(t *testing.T, target string) *http.Request {
+	var body bytes.Buffer
+	var form = mimepart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = part.Write([]byte("a"))
+	_ = form.Close()
+	var r = httptest.NewRequest(http.MethodPost, target, &body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	return r
+}
+
+// responseCode 回应中的code
+func responseCode(t *testing.T, w *httptest.ResponseRecorder) int {
+	var resp Response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+	return resp.Code
+}
+
+func TestHandleAuth(t *testing.T) {
+	var tests = []struct {
+		name    string //说明
+		token   string //配置的Token
+		utoken  string //配置的UToken
+		storage string //请求的存储服务
+		target  string //请求地址
+		header  string //请求头中携带的凭证 规则 token=admin
+		upload  bool   //是否为上传请求
+		code    int
+	}{
+		{"no token", "admin", "up", "Local", "/?operate=list", "", false, 401},
+		{"wrong token", "admin", "up", "Local", "/?operate=list&token=bad", "", false, 401},
+		{"query token", "admin", "up", "Local", "/?operate=list&token=admin", "", false, 200},
+		{"header token", "admin", "up", "Local", "/?operate=list", "token=admin", false, 200},
+		{"empty token", "", "up", "Local", "/?operate=list&token=", "", false, 401},
+		{"utoken list", "admin", "up", "Local", "/?operate=list&utoken=up", "", false, 401},
+		{"utoken delete", "admin", "up", "Local", "/?operate=delete&path=a.txt&utoken=up", "", false, 401},
+		{"utoken upload", "admin", "up", "Local", "/?operate=upload&utoken=up", "", true, 200},
+		{"header utoken upload", "admin", "up", "Local", "/?operate=upload", "utoken=up", true, 200},
+		{"utoken upload other", "admin", "up", "Other", "/?operate=upload&utoken=up", "", true, 401},
+		{"empty utoken upload", "admin", "", "Local", "/?operate=upload&utoken=", "", true, 401},
+		{"token upload other", "admin", "up", "Other", "/?operate=upload&token=admin", "", true, 200},
+	}
+	for _, test := range tests {
+		cleanup := useTestConfig(t, fmt.Sprintf(testAuthConfig, test.token, test.utoken))
+		var r = httptest.NewRequest(http.MethodGet, test.target, nil)
+		if test.upload {
+			r = uploadRequest(t, test.target)
+		}
+		if test.header != "" {
+			var kv = strings.SplitN(test.header, "=", 2)
+			r.Header.Set(kv[0], kv[1])
+		}
+		var w = httptest.NewRecorder()
+		Handle(w, r, test.storage)
+		if code := responseCode(t, w); code != test.code {
+			t.Errorf("%s: code = %d, want %d (%s)", test.name, code, test.code, w.Body.String())
+		}
+		cleanup()
+	}
+}
+
+func TestQuickUploadAuth(t *testing.T) {
+	var tests = []struct {
+		name   string
+		utoken string //配置的UToken
+		target string
+		code   int
+	}{
+		{"no utoken", "up", "/", 401},
+		{"wrong utoken", "up", "/?utoken=bad", 401},
+		{"admin token", "up", "/?token=admin", 401},
+		{"empty utoken", "", "/?utoken=", 401},
+		{"utoken", "up", "/?utoken=up", 200},
+	}
+	for _, test := range tests {
+		cleanup := useTestConfig(t, fmt.Sprintf(testAuthConfig, "admin", test.utoken))
+		var w = httptest.NewRecorder()
+		QuickUpload(w, uploadRequest(t, test.target))
+		if code := responseCode(t, w); code != test.code {
+			t.Errorf("%s: code = %d, want %d (%s)", test.name, code, test.code, w.Body.String())
+		}
+		cleanup()
+	}
+}
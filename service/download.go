@@ -0,0 +1,69 @@
+package service
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"Pines/storage"
+)
+
+// download 通过Pines下载文件 支持Range与条件请求 可用于私有存储桶
+// 默认以附件形式下载 携带 inline=true 时在浏览器中直接打开
+func download(w http.ResponseWriter, r *http.Request, store storage.Storage) {
+	var key = r.URL.Query().Get("path")
+	if key == "" || strings.HasSuffix(key, "/") {
+		WriteJSON(w, &Response{
+			Code:    500,
+			Message: "ErrorDownload:invalid path",
+		})
+		return
+	}
+	info, err := store.Stat(key)
+	if err != nil {
+		WriteError(w, "ErrorDownload", err)
+		return
+	}
+	//本地存储的目录路径不以 / 结尾 读取内容会在响应头发送后失败
+	if info.IsDir {
+		WriteJSON(w, &Response{
+			Code:    500,
+			Message: "ErrorDownload:invalid path",
+		})
+		return
+	}
+	var name = path.Base(key)
+	var header = w.Header()
+	header.Set("Access-Control-Allow-Origin", "*")
+	header.Set("Access-Control-Expose-Headers", "Content-Disposition, Content-Range, Accept-Ranges, ETag")
+	header.Set("Content-Disposition", disposition(r.URL.Query().Get("inline") == "true", name))
+	if info.ContentType == "" {
+		info.ContentType = mime.TypeByExtension(path.Ext(name))
+	}
+	if info.ContentType != "" {
+		header.Set("Content-Type", info.ContentType)
+	}
+	if info.ETag != "" {
+		header.Set("ETag", "\""+info.ETag+"\"")
+	}
+	reader := storage.NewObjectReader(store, key, info.Size)
+	defer reader.Close()
+	http.ServeContent(w, r, name, info.LastModified, reader)
+}
+
+// disposition 生成Content-Disposition 文件名按RFC 5987编码 兼容中文文件名
+func disposition(inline bool, name string) string {
+	var kind = "attachment"
+	if inline {
+		kind = "inline"
+	}
+	var ascii = strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, name)
+	return kind + "; filename=\"" + ascii + "\"; filename*=UTF-8''" + url.PathEscape(name)
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDownload(t *testing.T) {
+	_, store, cleanup := newTestLocal(t)
+	defer cleanup()
+	if err := store.Put("img/a.txt", strings.NewReader("hello")); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		path string
+		body string
+	}{
+		{"img/a.txt", "hello"},
+		{"img", `"message":"ErrorDownload:invalid path"`},
+		{"img/", `"message":"ErrorDownload:invalid path"`},
+	}
+	for _, test := range tests {
+		var w = httptest.NewRecorder()
+		download(w, httptest.NewRequest(http.MethodGet, "/api/local?operate=download&path="+test.path, nil), store)
+		if !strings.Contains(w.Body.String(), test.body) {
+			t.Errorf("download %s = %q, want %q", test.path, w.Body.String(), test.body)
+		}
+	}
+}
@@ -0,0 +1,529 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"Pines/config"
+	"Pines/storage"
+)
+
+// Handler 请求参数信息
+// Operate: 操作类型 [list,stat,sign,download,zip,delete,batchdelete,upload,extract,ingest,policy,callback,initiate,uploadpart,listparts,complete,abort,domain,mkdir,move,rename,copy,sync,diff,mirror]
+// Prefix: 操作的前缀(前缀意为操作所在的目录)
+// Path: 操作的绝对地址
+// Limit: 分页列举时的单页数量
+// Marker: 分页列举时上一页返回的游标
+// Dest: 移动与复制操作的目标绝对地址
+// Name: 重命名操作的新名称
+// Filename: 直传凭证未携带Path时使用的文件名
+// URL: 抓取上传的远程文件地址 抓取上传仅接受Token认证
+// Target: 复制与同步操作的目标存储服务 为空时在当前存储桶内操作
+// Expire: 临时下载地址与直传凭证的有效期 单位为秒
+// Inline: 下载时为true则在浏览器中直接打开 否则作为附件下载
+// Max: 打包下载目录时的总大小上限 解压上传时单个文件的大小上限 单位为字节
+// 分片上传的参数见 multipart.go 同步与比较的参数见 sync.go
+// 批量删除操作的请求体为需要删除的绝对路径组成的JSON数组
+
+const (
+	// maxListLimit 分页列举时单页数量的上限 与Cos/Oss单次列举的上限一致
+	maxListLimit = 1000
+	// maxBatchBody 批量操作请求体的大小上限
+	maxBatchBody = 1 << 20
+	// defaultSignExpire 临时下载地址的默认有效期
+	defaultSignExpire = time.Hour
+	// maxSignExpire 临时下载地址的最长有效期 与S3预签名的上限一致
+	maxSignExpire = 7 * 24 * time.Hour
+	// defaultPolicyExpire 直传凭证的默认有效期
+	defaultPolicyExpire = 15 * time.Minute
+	// maxPolicyExpire 直传凭证的最长有效期
+	maxPolicyExpire = time.Hour
+)
+
+// Handle 使用name对应的存储驱动处理请求 各个云存储服务共用该逻辑
+// 所有操作均需要Token认证 Default对应存储服务的上传操作额外接受UToken
+func Handle(w http.ResponseWriter, r *http.Request, name string) {
+	if Preflight(w, r) {
+		return
+	}
+	var conf = config.GetConfig()
+	var operate = r.URL.Query().Get("operate")
+	if !Authorized(r, conf) && !(operate == "upload" && name == conf.Default && UploadAuthorized(r, conf)) {
+		Unauthorized(w)
+		return
+	}
+	//初始化
+	store, err := storage.New(name, conf)
+	if err != nil {
+		WriteError(w, "ErrorInitClient", err)
+		return
+	}
+	switch operate {
+	case "list":
+		list(w, r, store)
+	case "stat":
+		stat(w, r, store)
+	case "sign":
+		sign(w, r, store)
+	case "download":
+		download(w, r, store)
+	case "zip":
+		zipDir(w, r, store)
+	case "delete":
+		remove(w, r, store, name, conf)
+	case "batchdelete":
+		batchRemove(w, r, store, name, conf)
+	case "upload":
+		upload(w, r, store, name, conf)
+	case "extract":
+		extract(w, r, store, name, conf)
+	case "ingest":
+		ingest(w, r, store, name, conf)
+	case "policy":
+		policy(w, r, store)
+	case "callback":
+		callback(w, r, store, name, conf)
+	case "initiate", "uploadpart", "listparts", "complete", "abort":
+		multipart(w, r, store, operate, name, conf)
+	case "domain":
+		WriteJSON(w, &Response{
+			Code:    200,
+			Message: store.Domain(),
+		})
+	case "mkdir":
+		mkdir(w, r, store)
+	case "move":
+		move(w, store, name, conf, r.URL.Query().Get("path"), r.URL.Query().Get("dest"))
+	case "rename":
+		rename(w, r, store, name, conf)
+	case "copy":
+		copyObject(w, r, store, name, conf)
+	case "sync":
+		syncDir(w, r, store, name, conf)
+	case "diff":
+		diffDir(w, r, store, name, conf)
+	case "mirror":
+		mirrorState(w, r, conf)
+	default:
+		WriteJSON(w, &Response{
+			Code:    500,
+			Message: "ErrorOperate:unsupported operate",
+		})
+	}
+}
+
+// list 列举当前目录下的文件 携带limit参数时分页列举 marker为上一页返回的next游标
+func list(w http.ResponseWriter, r *http.Request, store storage.Storage) {
+	var query = r.URL.Query()
+	var prefix = query.Get("prefix")
+	if query.Get("limit") == "" {
+		result, err := storage.ListAll(store, prefix)
+		if err != nil {
+			WriteError(w, "ErrorListObject", err)
+			return
+		}
+		WriteJSON(w, &List{
+			Code:    200,
+			Message: store.Domain(),
+			Data:    result,
+			Count:   len(result),
+		})
+		return
+	}
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 || limit > maxListLimit {
+		WriteJSON(w, &Response{
+			Code:    500,
+			Message: "ErrorListObject:invalid limit",
+		})
+		return
+	}
+	page, err := store.List(prefix, query.Get("marker"), limit)
+	if err != nil {
+		WriteError(w, "ErrorListObject", err)
+		return
+	}
+	WriteJSON(w, &List{
+		Code:    200,
+		Message: store.Domain(),
+		Data:    page.Objects,
+		Count:   len(page.Objects),
+		Next:    page.Next,
+	})
+}
+
+// stat 获取文件的完整元信息
+func stat(w http.ResponseWriter, r *http.Request, store storage.Storage) {
+	info, err := store.Stat(r.URL.Query().Get("path"))
+	if err != nil {
+		WriteError(w, "ErrorStat", err)
+		return
+	}
+	WriteJSON(w, &Response{
+		Code:    200,
+		Message: store.Domain(),
+		Data:    info,
+	})
+}
+
+// sign 生成临时下载地址
+func sign(w http.ResponseWriter, r *http.Request, store storage.Storage) {
+	expire, ok := parseExpire(r.URL.Query().Get("expire"), defaultSignExpire, maxSignExpire)
+	if !ok {
+		WriteJSON(w, &Response{
+			Code:    500,
+			Message: "ErrorSign:invalid expire",
+		})
+		return
+	}
+	link, err := store.Sign(r.URL.Query().Get("path"), expire)
+	if err != nil {
+		WriteError(w, "ErrorSign", err)
+		return
+	}
+	WriteJSON(w, &Response{
+		Code:    200,
+		Message: "ok",
+		Data:    link,
+	})
+}
+
+// remove 删除文件 path为需要删除的文件绝对路径 以 / 结尾时递归删除整个目录
+func remove(w http.ResponseWriter, r *http.Request, store storage.Storage, name string, conf *config.Config) {
+	var path = r.URL.Query().Get("path")
+	if strings.HasSuffix(path, "/") {
+		result := storage.DeleteDir(store, path)
+		mirrorDelete(conf, name, []string{path}, result)
+		writeResult(w, "ErrorObjectDelete", result)
+		return
+	}
+	if err := store.Delete(path); err != nil {
+		WriteError(w, "ErrorObjectDelete", err)
+		return
+	}
+	mirror(conf, name, "delete", path)
+	WriteJSON(w, &Response{
+		Code:    200,
+		Message: "ok",
+	})
+}
+
+// batchRemove 批量删除请求体中的文件 以 / 结尾的路径会递归删除整个目录
+func batchRemove(w http.ResponseWriter, r *http.Request, store storage.Storage, name string, conf *config.Config) {
+	var keys []string
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBody)).Decode(&keys); err != nil {
+		WriteError(w, "ErrorObjectDelete", err)
+		return
+	}
+	var files, dirs []string
+	var result = new(storage.Result)
+	for _, key := range keys {
+		if strings.HasSuffix(key, "/") {
+			dirs = append(dirs, key)
+			result.Merge(storage.DeleteDir(store, key))
+		} else if key != "" {
+			files = append(files, key)
+		}
+	}
+	result.Merge(storage.DeleteMulti(store, files))
+	mirrorDelete(conf, name, dirs, result)
+	writeResult(w, "ErrorObjectDelete", result)
+}
+
+// upload 上传文件到prefix目录下
+func upload(w http.ResponseWriter, r *http.Request, store storage.Storage, name string, conf *config.Config) {
+	var _, header, err = r.FormFile("file")
+	if err != nil {
+		WriteError(w, "ErrorUpload", err)
+		return
+	}
+	var prefix string
+	if r.MultipartForm != nil {
+		values := r.MultipartForm.Value["prefix"]
+		if len(values) > 0 {
+			prefix = values[0]
+		}
+	}
+	dst := header.Filename
+	source, err := header.Open()
+	if err != nil {
+		WriteError(w, "ErrorUpload", err)
+		return
+	}
+	defer source.Close()
+	if err = store.Put(prefix+dst, source); err != nil {
+		WriteError(w, "ErrorObjectUpload", err)
+		return
+	}
+	mirror(conf, name, "put", prefix+dst)
+	WriteJSON(w, &Response{
+		Code:    200,
+		Message: "ok",
+		Data:    store.Domain() + prefix + dst,
+	})
+}
+
+// policy 签发浏览器直传凭证 文件不再经过Pines中转
+// path为对象的绝对路径 为空时使用 prefix+filename
+func policy(w http.ResponseWriter, r *http.Request, store storage.Storage) {
+	var query = r.URL.Query()
+	var key = query.Get("path")
+	if key == "" {
+		key = query.Get("prefix") + query.Get("filename")
+	}
+	if key == "" || strings.HasSuffix(key, "/") {
+		WriteJSON(w, &Response{
+			Code:    500,
+			Message: "ErrorPolicy:invalid path",
+		})
+		return
+	}
+	expire, ok := parseExpire(query.Get("expire"), defaultPolicyExpire, maxPolicyExpire)
+	if !ok {
+		WriteJSON(w, &Response{
+			Code:    500,
+			Message: "ErrorPolicy:invalid expire",
+		})
+		return
+	}
+	result, err := storage.PresignUpload(store, key, expire)
+	if err != nil {
+		WriteError(w, "ErrorPolicy", err)
+		return
+	}
+	WriteJSON(w, &Response{
+		Code:    200,
+		Message: store.Domain() + key,
+		Data:    result,
+	})
+}
+
+// callback 浏览器直传完成后的回调 确认对象已经上传并返回访问地址
+func callback(w http.ResponseWriter, r *http.Request, store storage.Storage, name string, conf *config.Config) {
+	var key = r.URL.Query().Get("path")
+	info, err := store.Stat(key)
+	if err != nil {
+		WriteError(w, "ErrorCallback", err)
+		return
+	}
+	mirror(conf, name, "put", key)
+	WriteJSON(w, &Response{
+		Code:    200,
+		Message: store.Domain() + key,
+		Data:    info,
+	})
+}
+
+// mkdir 在prefix目录下创建dirname目录
+func mkdir(w http.ResponseWriter, r *http.Request, store storage.Storage) {
+	var prefix = r.URL.Query().Get("prefix")
+	var dirname = r.URL.Query().Get("dirname")
+	if err := store.Mkdir(prefix + dirname); err != nil {
+		WriteError(w, "ErrorMkdir", err)
+		return
+	}
+	WriteJSON(w, &Response{
+		Code:    200,
+		Message: "ok",
+	})
+}
+
+// QuickUpload 快捷上传 使用UToken认证 上传到配置文件中Default指定的存储服务
+func QuickUpload(w http.ResponseWriter, r *http.Request) {
+	if Preflight(w, r) {
+		return
+	}
+	var conf = config.GetConfig()
+	if !UploadAuthorized(r, conf) {
+		Unauthorized(w)
+		return
+	}
+	store, err := storage.New(conf.Default, conf)
+	if err != nil {
+		WriteError(w, "ErrorInitClient", err)
+		return
+	}
+	upload(w, r, store, conf.Default, conf)
+}
+
+// move 移动文件或目录 path与dest均以 / 结尾时表示移动整个目录
+func move(w http.ResponseWriter, store storage.Storage, name string, conf *config.Config, src, dst string) {
+	if !validDest(src, dst, true) {
+		WriteJSON(w, &Response{
+			Code:    500,
+			Message: "ErrorMove:invalid path or dest",
+		})
+		return
+	}
+	if !strings.HasSuffix(src, "/") {
+		if err := store.Move(src, dst); err != nil {
+			WriteError(w, "ErrorMove", err)
+			return
+		}
+		if queueMirror(conf, name, "put", dst) {
+			queueMirror(conf, name, "delete", src)
+			flushMirror(conf)
+		}
+		WriteJSON(w, &Response{
+			Code:    200,
+			Message: "ok",
+			Data:    store.Domain() + dst,
+		})
+		return
+	}
+	result := storage.MoveDir(store, src, dst)
+	mirrorMove(conf, name, src, dst, result, true)
+	writeResult(w, "ErrorMove", result)
+}
+
+// rename 在原目录下重命名文件或目录
+func rename(w http.ResponseWriter, r *http.Request, store storage.Storage, name string, conf *config.Config) {
+	var src = r.URL.Query().Get("path")
+	var newName = strings.Trim(r.URL.Query().Get("name"), "/")
+	if newName == "" || strings.Contains(newName, "/") {
+		WriteJSON(w, &Response{
+			Code:    500,
+			Message: "ErrorMove:invalid name",
+		})
+		return
+	}
+	var dir = strings.TrimSuffix(src, "/")
+	dir = dir[:strings.LastIndex(dir, "/")+1]
+	if strings.HasSuffix(src, "/") {
+		newName += "/"
+	}
+	move(w, store, name, conf, src, dir+newName)
+}
+
+// copyObject 复制文件或目录 path与dest均以 / 结尾时表示复制整个目录
+// 同一存储桶内使用服务端复制 复制到其他存储服务时以流的方式中转
+func copyObject(w http.ResponseWriter, r *http.Request, store storage.Storage, name string, conf *config.Config) {
+	var src = r.URL.Query().Get("path")
+	var dst = r.URL.Query().Get("dest")
+	var targetName = r.URL.Query().Get("target")
+	if targetName == "" {
+		targetName = name
+	}
+	var native = targetName == name
+	if !validDest(src, dst, native) {
+		WriteJSON(w, &Response{
+			Code:    500,
+			Message: "ErrorCopy:invalid path or dest",
+		})
+		return
+	}
+	var target = store
+	if !native {
+		var err error
+		if target, err = storage.New(targetName, conf); err != nil {
+			WriteError(w, "ErrorInitClient", err)
+			return
+		}
+	}
+	if strings.HasSuffix(src, "/") {
+		var result *storage.Result
+		if native {
+			result = storage.CopyDir(store, src, dst)
+		} else {
+			result = storage.TransferDir(store, target, src, dst)
+		}
+		//复制到的存储服务按其自身的镜像配置复制
+		mirrorMove(conf, targetName, src, dst, result, false)
+		writeResult(w, "ErrorCopy", result)
+		return
+	}
+	var err error
+	if native {
+		err = store.Copy(src, dst)
+	} else {
+		err = storage.Transfer(store, target, src, dst)
+	}
+	if err != nil {
+		WriteError(w, "ErrorCopy", err)
+		return
+	}
+	mirror(conf, targetName, "put", dst)
+	WriteJSON(w, &Response{
+		Code:    200,
+		Message: "ok",
+		Data:    target.Domain() + dst,
+	})
+}
+
+// parseExpire 解析以秒为单位的有效期 为空时使用def 超出max时视为无效
+func parseExpire(value string, def, max time.Duration) (time.Duration, bool) {
+	if value == "" {
+		return def, true
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > max {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// validDest 源路径与目标路径均不为空 且同为文件或同为目录
+// same为true时表示在同一存储服务内操作 文件不能移动或复制到自身 目录不能移动或复制到自身或自身之下
+func validDest(src, dst string, same bool) bool {
+	if src == "" || dst == "" || strings.HasSuffix(src, "/") != strings.HasSuffix(dst, "/") {
+		return false
+	}
+	if !same {
+		return true
+	}
+	if !strings.HasSuffix(src, "/") {
+		return dst != src
+	}
+	return !strings.HasPrefix(dst, src)
+}
+
+// mirrorDelete 复制删除操作 dirs中没有失败对象的目录整体删除 其余只删除已经删除成功的文件
+func mirrorDelete(conf *config.Config, name string, dirs []string, result *storage.Result) {
+	var keys []string
+	var clean = make(map[string]bool)
+	for _, dir := range dirs {
+		clean[dir] = true
+		for _, failure := range result.Failed {
+			if strings.HasPrefix(failure.Key, dir) {
+				clean[dir] = false
+			}
+		}
+		if clean[dir] {
+			keys = append(keys, dir)
+		}
+	}
+	for _, key := range result.Done {
+		var covered bool
+		for _, dir := range dirs {
+			if clean[dir] && strings.HasPrefix(key, dir) {
+				covered = true
+			}
+		}
+		if !covered {
+			keys = append(keys, key)
+		}
+	}
+	mirror(conf, name, "delete", keys...)
+}
+
+// writeResult 输出批量操作的结果 count为操作成功的数量 存在失败的对象时返回失败的数量
+func writeResult(w http.ResponseWriter, kind string, result *storage.Result) {
+	if len(result.Failed) > 0 {
+		WriteJSON(w, &List{
+			Code:    500,
+			Count:   len(result.Done),
+			Message: kind + ":" + strconv.Itoa(len(result.Failed)) + " objects failed",
+			Data:    result,
+		})
+		return
+	}
+	WriteJSON(w, &List{
+		Code:    200,
+		Count:   len(result.Done),
+		Message: "ok",
+		Data:    result,
+	})
+}
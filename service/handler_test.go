@@ -0,0 +1,32 @@
+package service
+
+import "testing"
+
+func TestValidDest(t *testing.T) {
+	var tests = []struct {
+		src, dst string
+		same     bool
+		want     bool
+	}{
+		{"", "a.png", true, false},
+		{"a.png", "", true, false},
+		{"a.png", "b/", true, false},
+		{"a/", "b.png", true, false},
+		{"a.png", "a.png", true, false},
+		{"a.png", "a.png.bak", true, true},
+		{"report", "report-v2", true, true},
+		{"img/a.png", "img/b.png", true, true},
+		{"img/a.png", "img/a.png", false, true},
+		{"a/", "a/", true, false},
+		{"a/", "a/b/", true, false},
+		{"a/", "ab/", true, true},
+		{"a/b/", "a/", true, true},
+		{"a/", "a/", false, true},
+		{"a/", "a/b/", false, true},
+	}
+	for _, test := range tests {
+		if got := validDest(test.src, test.dst, test.same); got != test.want {
+			t.Errorf("validDest(%q, %q, %v) = %v, want %v", test.src, test.dst, test.same, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Pines/config"
+	"Pines/storage"
+)
+
+// newTestLocal 在临时目录中创建本地存储Local 上传与删除会镜像复制到本地存储目标Backup
+// 返回配置、Local存储与清理函数
+func newTestLocal(t *testing.T) (*config.Config, storage.Storage, func()) {
+	dir, err := ioutil.TempDir("", "pines-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf = &config.Config{
+		Local: config.Local{Root: filepath.Join(dir, "primary")},
+		Targets: []config.Target{
+			{Name: "Backup", Type: "Local", Local: config.Local{Root: filepath.Join(dir, "backup")}},
+		},
+		Mirror: config.Mirror{
+			Targets: map[string][]string{"Local": {"Backup"}},
+			Queue:   filepath.Join(dir, "queue.json"),
+		},
+	}
+	store, err := storage.New("Local", conf)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return conf, store, func() {
+		_ = os.RemoveAll(dir)
+	}
+}
+
+// useTestConfig 在临时目录中写入config.yaml并切换工作目录 返回清理函数
+func useTestConfig(t *testing.T, content string) func() {
+	dir, err := ioutil.TempDir("", "pines-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "config.yaml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		_ = os.Chdir(wd)
+		_ = os.RemoveAll(dir)
+	}
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"Pines/config"
+	"Pines/storage"
+)
+
+const (
+	// defaultIngestMax 抓取远程文件的默认大小上限
+	defaultIngestMax = 100 << 20
+	// ingestTimeout 抓取远程文件的超时时间 包含下载与上传到存储服务
+	ingestTimeout = 60 * time.Second
+	// sniffLen 用于识别文件类型的字节数 与 http.DetectContentType 一致
+	sniffLen = 512
+	// maxIngestRedirects 抓取远程文件时跟随重定向的次数上限
+	maxIngestRedirects = 3
+)
+
+var (
+	// errIngestEmpty 远程文件为空
+	errIngestEmpty = errors.New("empty remote file")
+	// errIngestAddress 远程地址解析为内网、回环或链路本地地址
+	errIngestAddress = errors.New("private address not allowed")
+	// errIngestRedirects 重定向次数过多
+	errIngestRedirects = errors.New("too many redirects")
+)
+
+// privateNetworks 禁止抓取的保留网段 net.IP 的方法未覆盖的部分
+var privateNetworks = parseNetworks(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10",
+	"fc00::/7",
+)
+
+// sniffExtensions 识别出的文件类型对应的扩展名 用于没有扩展名的远程文件
+var sniffExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"image/bmp":       ".bmp",
+	"image/x-icon":    ".ico",
+	"video/mp4":       ".mp4",
+	"video/webm":      ".webm",
+	"audio/mpeg":      ".mp3",
+	"application/pdf": ".pdf",
+	"application/zip": ".zip",
+}
+
+// ingestClient 抓取远程文件使用的客户端 在DNS解析后检查连接的地址 避免通过域名或重定向访问内网服务
+var ingestClient = &http.Client{
+	Timeout: ingestTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: publicAddress,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxIngestRedirects {
+			return errIngestRedirects
+		}
+		return nil
+	},
+}
+
+// ingest 由服务端下载url对应的远程文件并保存到prefix目录下
+// name为保存的文件名 为空时使用url中的文件名 没有扩展名时根据文件内容补全
+// 携带 max 参数时使用max作为文件大小上限 单位为字节
+func ingest(w http.ResponseWriter, r *http.Request, store storage.Storage, name string, conf *config.Config) {
+	var query = r.URL.Query()
+	link, err := url.Parse(query.Get("url"))
+	if err != nil || (link.Scheme != "http" && link.Scheme != "https") || link.Host == "" {
+		WriteJSON(w, &Response{
+			Code:    500,
+			Message: "ErrorIngest:invalid url",
+		})
+		return
+	}
+	var max int64 = defaultIngestMax
+	if value := query.Get("max"); value != "" {
+		if max, err = strconv.ParseInt(value, 10, 64); err != nil || max <= 0 {
+			WriteJSON(w, &Response{
+				Code:    500,
+				Message: "ErrorIngest:invalid max",
+			})
+			return
+		}
+	}
+	var filename = query.Get("name")
+	if filename == "" {
+		filename = path.Base(link.Path)
+	}
+	if filename == "." || filename == "/" || strings.Contains(filename, "/") {
+		filename = "index"
+	}
+	resp, err := ingestClient.Get(link.String())
+	if err != nil {
+		WriteError(w, "ErrorIngest", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		WriteError(w, "ErrorIngest", fmt.Errorf("GET %d %s", resp.StatusCode, link.String()))
+		return
+	}
+	if resp.ContentLength > max {
+		WriteError(w, "ErrorIngest", errTooLarge)
+		return
+	}
+	//读取文件头识别文件类型 随后与剩余内容一起上传
+	var body = &maxReader{reader: resp.Body, remain: max}
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		WriteError(w, "ErrorIngest", err)
+		return
+	}
+	if n == 0 {
+		WriteError(w, "ErrorIngest", errIngestEmpty)
+		return
+	}
+	head = head[:n]
+	var contentType = http.DetectContentType(head)
+	if path.Ext(filename) == "" {
+		filename += sniffExtension(contentType)
+	}
+	var key = query.Get("prefix") + filename
+	if err = store.Put(key, io.MultiReader(bytes.NewReader(head), body)); err != nil {
+		WriteError(w, "ErrorIngest", err)
+		return
+	}
+	mirror(conf, name, "put", key)
+	WriteJSON(w, &Response{
+		Code:    200,
+		Message: "ok",
+		Data:    store.Domain() + key,
+	})
+}
+
+// sniffExtension 文件类型对应的扩展名 无法确定时返回空字符串
+func sniffExtension(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	if ext, ok := sniffExtensions[mediaType]; ok {
+		return ext
+	}
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// publicAddress 拒绝连接内网、回环、链路本地等非公网地址 address为解析后的IP与端口
+func publicAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return errIngestAddress
+	}
+	return nil
+}
+
+// publicIP 是否为公网地址
+func publicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseNetworks 解析CIDR格式的网段
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPublicIP(t *testing.T) {
+	var tests = []struct {
+		ip   string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"2001:4860:4860::8888", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"0.0.0.0", false},
+		{"10.1.2.3", false},
+		{"172.20.0.1", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"::ffff:127.0.0.1", false},
+	}
+	for _, test := range tests {
+		if got := publicIP(net.ParseIP(test.ip)); got != test.want {
+			t.Errorf("publicIP(%s) = %v, want %v", test.ip, got, test.want)
+		}
+	}
+}
+
+func TestIngestClientRejectsLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+	_, err := ingestClient.Get(server.URL)
+	if err == nil || !strings.Contains(err.Error(), errIngestAddress.Error()) {
+		t.Errorf("Get(%s) err = %v, want %v", server.URL, err, errIngestAddress)
+	}
+}
@@ -0,0 +1,392 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"Pines/config"
+	"Pines/storage"
+)
+
+const (
+	// defaultMirrorRetry 复制失败后默认的自动重试次数
+	defaultMirrorRetry = 5
+	// defaultMirrorQueue 复制队列默认的保存文件名 位于系统临时目录下
+	defaultMirrorQueue = "pines-mirror.json"
+	// MirrorInterval 独立运行模式下自动重试的间隔
+	MirrorInterval = time.Minute
+	// mirrorBudget 未启动后台复制时 请求返回前执行复制任务的最长时间 需要小于 now.json 中的 maxDuration
+	mirrorBudget = 3 * time.Second
+)
+
+// mirrorJob 等待复制的任务
+type mirrorJob struct {
+	ID       string    `json:"id"`       //任务ID
+	Source   string    `json:"source"`   //主存储服务
+	Target   string    `json:"target"`   //备份存储服务
+	Operate  string    `json:"operate"`  //操作类型 put/delete
+	Key      string    `json:"key"`      //对象的绝对路径 以 / 结尾时表示复制或删除整个目录
+	Attempts int       `json:"attempts"` //已经失败的次数 为0时表示等待首次复制
+	Error    string    `json:"error"`    //最后一次失败的原因
+	Time     time.Time `json:"time"`     //加入队列或最后一次尝试的时间
+}
+
+// mirrorStatus 镜像复制的状态
+type mirrorStatus struct {
+	Targets    map[string][]string `json:"targets"`    //配置的备份存储服务
+	Replicated int64               `json:"replicated"` //服务启动以来复制成功的对象数量
+	Failures   int64               `json:"failures"`   //服务启动以来复制失败的次数
+	Pending    []*mirrorJob        `json:"pending"`    //等待复制或自动重试的任务
+	Failed     []*mirrorJob        `json:"failed"`     //超过重试次数的任务 需要手动重试
+}
+
+var (
+	// mirrorMu 保护复制队列文件、执行中的任务与统计数据
+	mirrorMu sync.Mutex
+	// mirrorRunning 正在执行的任务ID 避免后台复制与重试同时执行同一个任务
+	mirrorRunning = make(map[string]bool)
+	// mirrorReplicated 复制成功的对象数量
+	mirrorReplicated int64
+	// mirrorFailures 复制失败的次数
+	mirrorFailures int64
+	// mirrorOnce 启动后台复制协程
+	mirrorOnce sync.Once
+	// mirrorBackground 是否由后台协程执行复制任务 仅在独立运行模式下启用
+	mirrorBackground bool
+	// mirrorWake 通知后台复制协程处理新加入的任务 携带触发请求时读取的配置
+	mirrorWake = make(chan *config.Config, 1)
+)
+
+// StartMirror 启动后台复制 独立运行模式下调用
+// 启动后复制任务写入队列后立即返回 由后台协程执行 失败的任务每隔MirrorInterval自动重试
+// 未启动时 复制任务在请求返回前执行 适用于函数返回后即被暂停的Serverless部署
+func StartMirror() {
+	mirrorMu.Lock()
+	mirrorBackground = true
+	mirrorMu.Unlock()
+	go func() {
+		for range time.Tick(MirrorInterval) {
+			RetryMirror(config.GetConfig(), false)
+		}
+	}()
+}
+
+// mirror 将主存储服务上的上传或删除操作复制到配置的备份存储服务
+// 任务写入队列后由flushMirror执行 同一请求中的多个操作应使用queueMirror加入后统一执行
+// operate为put时从主存储服务读取对象后上传 为delete时删除备份存储服务中的对象
+func mirror(conf *config.Config, name, operate string, keys ...string) {
+	if queueMirror(conf, name, operate, keys...) {
+		flushMirror(conf)
+	}
+}
+
+// queueMirror 将复制任务按顺序加入队列 没有需要复制的备份存储服务时返回false
+// 同一备份对象只有最后一次操作决定结果 加入新任务时移除该对象尚未执行的旧任务
+func queueMirror(conf *config.Config, name, operate string, keys ...string) bool {
+	var jobs []*mirrorJob
+	for _, target := range conf.Mirror.Targets[name] {
+		//复制到自身会在读取的同时覆盖源对象
+		if target == name {
+			continue
+		}
+		for _, key := range keys {
+			jobs = append(jobs, &mirrorJob{
+				ID:      mirrorID(),
+				Source:  name,
+				Target:  target,
+				Operate: operate,
+				Key:     key,
+				Time:    time.Now(),
+			})
+		}
+	}
+	if len(jobs) == 0 {
+		return false
+	}
+	var added = make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		added[mirrorKey(job)] = true
+	}
+	mirrorMu.Lock()
+	var queue []*mirrorJob
+	for _, job := range loadMirror(conf) {
+		//正在执行的旧任务保留 新任务会等待其完成后执行
+		if mirrorRunning[job.ID] || !added[mirrorKey(job)] {
+			queue = append(queue, job)
+		}
+	}
+	_ = saveMirror(conf, append(queue, jobs...))
+	mirrorMu.Unlock()
+	return true
+}
+
+// flushMirror 执行队列中的复制任务
+// 启动后台复制时通知后台协程后立即返回 否则在mirrorBudget内执行新任务 剩余时间重试之前失败的任务
+// 超出时间未执行的任务保留在队列中 由之后的请求或 retry=true 执行
+func flushMirror(conf *config.Config) {
+	mirrorMu.Lock()
+	var background = mirrorBackground
+	mirrorMu.Unlock()
+	if background {
+		wakeMirror(conf)
+		return
+	}
+	var start = time.Now()
+	var deadline = start.Add(mirrorBudget)
+	processMirror(conf, deadline, func(job *mirrorJob) bool {
+		return job.Attempts == 0
+	})
+	//Serverless部署没有定时重试 利用剩余时间重试本次请求之前失败的任务
+	var limit = mirrorRetry(conf)
+	processMirror(conf, deadline, func(job *mirrorJob) bool {
+		return job.Attempts > 0 && job.Attempts < limit && job.Time.Before(start)
+	})
+}
+
+// wakeMirror 通知后台复制协程处理新加入的任务 协程在首次调用时启动
+// 每次处理都使用通知携带的配置 配置文件修改后无需重启
+func wakeMirror(conf *config.Config) {
+	mirrorOnce.Do(func() {
+		go func() {
+			for conf := range mirrorWake {
+				processMirror(conf, time.Time{}, func(job *mirrorJob) bool {
+					return job.Attempts == 0
+				})
+			}
+		}()
+	})
+	//已有未处理的通知时替换为最新的配置
+	select {
+	case <-mirrorWake:
+	default:
+	}
+	select {
+	case mirrorWake <- conf:
+	default:
+	}
+}
+
+// mirrorKey 任务对应的备份对象 同一备份对象的任务需要按顺序执行
+func mirrorKey(job *mirrorJob) string {
+	return job.Target + "\x00" + job.Key
+}
+
+// runMirror 执行一个复制任务
+func runMirror(conf *config.Config, job *mirrorJob) error {
+	target, err := storage.New(job.Target, conf)
+	if err != nil {
+		return err
+	}
+	if job.Operate == "delete" {
+		if strings.HasSuffix(job.Key, "/") {
+			return resultError(storage.DeleteDir(target, job.Key))
+		}
+		return target.Delete(job.Key)
+	}
+	source, err := storage.New(job.Source, conf)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(job.Key, "/") {
+		return resultError(storage.TransferDir(source, target, job.Key, job.Key))
+	}
+	return storage.Transfer(source, target, job.Key, job.Key)
+}
+
+// resultError 批量操作中第一个失败的对象
+func resultError(result *storage.Result) error {
+	if len(result.Failed) > 0 {
+		return errors.New(result.Failed[0].Key + ":" + result.Failed[0].Error)
+	}
+	return nil
+}
+
+// RetryMirror 重试队列中的任务 force为true时同时重试超过重试次数的任务
+func RetryMirror(conf *config.Config, force bool) {
+	var limit = mirrorRetry(conf)
+	processMirror(conf, time.Time{}, func(job *mirrorJob) bool {
+		return force || job.Attempts < limit
+	})
+}
+
+// processMirror 按队列顺序执行match选中的任务 完成后按任务ID更新队列
+// 同一备份对象的任务按加入顺序执行 前一个任务正在执行或未被选中时 后面的任务等待下一次处理
+// 执行任务时不持有锁 避免阻塞新任务加入队列 deadline不为零时超过该时间后不再执行新的任务
+func processMirror(conf *config.Config, deadline time.Time, match func(job *mirrorJob) bool) {
+	var jobs []*mirrorJob
+	var busy = make(map[string]bool)
+	var queued = make(map[string]bool)
+	mirrorMu.Lock()
+	for _, job := range loadMirror(conf) {
+		var key = mirrorKey(job)
+		queued[job.ID] = true
+		if !busy[key] && !mirrorRunning[job.ID] && match(job) {
+			mirrorRunning[job.ID] = true
+			jobs = append(jobs, job)
+		}
+		busy[key] = true
+	}
+	mirrorMu.Unlock()
+	if len(jobs) == 0 {
+		return
+	}
+	var results = make(map[string]error)
+	for _, job := range jobs {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			break
+		}
+		results[job.ID] = runMirror(conf, job)
+	}
+	mirrorMu.Lock()
+	defer mirrorMu.Unlock()
+	for _, job := range jobs {
+		delete(mirrorRunning, job.ID)
+	}
+	var queue = loadMirror(conf)
+	//执行期间加入的同一备份对象的任务 需要在本次处理完成后执行
+	var later = make(map[string]bool)
+	for _, job := range queue {
+		if !queued[job.ID] {
+			later[mirrorKey(job)] = true
+		}
+	}
+	var remain []*mirrorJob
+	var wake bool
+	for _, job := range queue {
+		err, ok := results[job.ID]
+		if !ok {
+			remain = append(remain, job)
+			continue
+		}
+		if err == nil {
+			mirrorReplicated++
+		} else {
+			mirrorFailures++
+		}
+		//失败的任务已被之后加入的任务取代 不再重试
+		if later[mirrorKey(job)] {
+			wake = true
+			continue
+		}
+		if err == nil {
+			continue
+		}
+		job.Attempts++
+		job.Error = err.Error()
+		job.Time = time.Now()
+		remain = append(remain, job)
+	}
+	_ = saveMirror(conf, remain)
+	//未启动后台复制时 新任务由加入它的请求执行
+	if wake && mirrorBackground {
+		wakeMirror(conf)
+	}
+}
+
+// mirrorMove 复制移动或复制目录的结果 dst中的对象上传到备份存储服务 remove为true时删除src中已移动的对象
+// 全部成功时按整个目录复制 否则只复制操作成功的对象 result.Done为源对象的路径
+func mirrorMove(conf *config.Config, name, src, dst string, result *storage.Result, remove bool) {
+	var queued bool
+	if len(result.Failed) == 0 {
+		queued = queueMirror(conf, name, "put", dst)
+		if remove {
+			queued = queueMirror(conf, name, "delete", src) || queued
+		}
+	} else {
+		var puts []string
+		for _, key := range result.Done {
+			puts = append(puts, dst+strings.TrimPrefix(key, src))
+		}
+		queued = queueMirror(conf, name, "put", puts...)
+		if remove {
+			queued = queueMirror(conf, name, "delete", result.Done...) || queued
+		}
+	}
+	if queued {
+		flushMirror(conf)
+	}
+}
+
+// mirrorState 查看镜像复制的状态 携带 retry=true 时先立即重试所有失败的任务
+func mirrorState(w http.ResponseWriter, r *http.Request, conf *config.Config) {
+	if r.URL.Query().Get("retry") == "true" {
+		RetryMirror(conf, true)
+	}
+	var limit = mirrorRetry(conf)
+	mirrorMu.Lock()
+	var status = &mirrorStatus{
+		Targets:    conf.Mirror.Targets,
+		Replicated: mirrorReplicated,
+		Failures:   mirrorFailures,
+	}
+	for _, job := range loadMirror(conf) {
+		if job.Attempts < limit {
+			status.Pending = append(status.Pending, job)
+		} else {
+			status.Failed = append(status.Failed, job)
+		}
+	}
+	mirrorMu.Unlock()
+	WriteJSON(w, &List{
+		Code:    200,
+		Count:   len(status.Pending) + len(status.Failed),
+		Message: "ok",
+		Data:    status,
+	})
+}
+
+// loadMirror 读取复制队列 调用方需要持有mirrorMu
+func loadMirror(conf *config.Config) []*mirrorJob {
+	var queue []*mirrorJob
+	data, err := ioutil.ReadFile(mirrorQueue(conf))
+	if err != nil {
+		return nil
+	}
+	_ = json.Unmarshal(data, &queue)
+	return queue
+}
+
+// saveMirror 保存复制队列 调用方需要持有mirrorMu
+func saveMirror(conf *config.Config, queue []*mirrorJob) error {
+	data, err := json.Marshal(queue)
+	if err != nil {
+		return err
+	}
+	var name = mirrorQueue(conf)
+	if err = os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, data, 0644)
+}
+
+// mirrorQueue 复制队列的保存文件
+func mirrorQueue(conf *config.Config) string {
+	if conf.Mirror.Queue != "" {
+		return conf.Mirror.Queue
+	}
+	return filepath.Join(os.TempDir(), defaultMirrorQueue)
+}
+
+// mirrorRetry 自动重试的次数
+func mirrorRetry(conf *config.Config) int {
+	if conf.Mirror.Retry > 0 {
+		return conf.Mirror.Retry
+	}
+	return defaultMirrorRetry
+}
+
+// mirrorID 生成任务ID
+func mirrorID() string {
+	var id = make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"Pines/config"
+	"Pines/storage"
+)
+
+func TestMirror(t *testing.T) {
+	conf, primary, cleanup := newTestLocal(t)
+	defer cleanup()
+	backup, err := storage.New("Backup", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = primary.Put("img/a.png", strings.NewReader("a")); err != nil {
+		t.Fatal(err)
+	}
+	mirror(conf, "Local", "put", "img/a.png")
+	waitMirror(t, conf)
+	if _, err = backup.Stat("img/a.png"); err != nil {
+		t.Fatal("put was not mirrored:", err)
+	}
+
+	//移动在备份存储服务中按上传新路径、删除原路径执行
+	if err = primary.Move("img/a.png", "img/b.png"); err != nil {
+		t.Fatal(err)
+	}
+	mirror(conf, "Local", "put", "img/b.png")
+	mirror(conf, "Local", "delete", "img/a.png")
+	waitMirror(t, conf)
+	if _, err = backup.Stat("img/b.png"); err != nil {
+		t.Error("move destination was not mirrored:", err)
+	}
+	if _, err = backup.Stat("img/a.png"); err == nil {
+		t.Error("move source was not deleted from the backup")
+	}
+
+	//主存储服务中不存在的对象复制失败 保留在队列中等待重试
+	mirror(conf, "Local", "put", "missing.png")
+	waitMirror(t, conf)
+	mirrorMu.Lock()
+	queue := loadMirror(conf)
+	mirrorMu.Unlock()
+	if len(queue) != 1 || queue[0].Key != "missing.png" || queue[0].Attempts != 1 {
+		t.Errorf("queue = %+v", queue)
+	}
+
+	//同一对象的新任务取代失败的旧任务
+	if err = primary.Put("missing.png", strings.NewReader("m")); err != nil {
+		t.Fatal(err)
+	}
+	mirror(conf, "Local", "put", "missing.png")
+	waitMirror(t, conf)
+	mirrorMu.Lock()
+	queue = loadMirror(conf)
+	mirrorMu.Unlock()
+	if len(queue) != 0 {
+		t.Errorf("queue = %+v", queue)
+	}
+	if _, err = backup.Stat("missing.png"); err != nil {
+		t.Error("put was not mirrored after retry:", err)
+	}
+}
+
+func TestProcessMirrorOrder(t *testing.T) {
+	conf, primary, cleanup := newTestLocal(t)
+	defer cleanup()
+	for _, key := range []string{"a.png", "b.png"} {
+		if err := primary.Put(key, strings.NewReader(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//先删除后上传 删除失败时上传也不能提前执行
+	var jobs = []*mirrorJob{
+		{ID: "1", Source: "Local", Target: "Backup", Operate: "delete", Key: "a.png"},
+		{ID: "2", Source: "Local", Target: "Backup", Operate: "put", Key: "a.png"},
+		{ID: "3", Source: "Local", Target: "Backup", Operate: "put", Key: "b.png"},
+	}
+	mirrorMu.Lock()
+	_ = saveMirror(conf, jobs)
+	mirrorMu.Unlock()
+	var ids = func() []string {
+		mirrorMu.Lock()
+		defer mirrorMu.Unlock()
+		var ids []string
+		for _, job := range loadMirror(conf) {
+			ids = append(ids, job.ID)
+		}
+		return ids
+	}
+	var all = func(job *mirrorJob) bool { return true }
+	processMirror(conf, time.Time{}, all)
+	//删除的对象不存在而失败 上传等待删除成功或被取代
+	if got := ids(); strings.Join(got, ",") != "1,2" {
+		t.Errorf("queue after first run = %v, want [1 2]", got)
+	}
+	processMirror(conf, time.Time{}, all)
+	if got := ids(); strings.Join(got, ",") != "1,2" {
+		t.Errorf("queue after second run = %v, want [1 2]", got)
+	}
+	mirror(conf, "Local", "put", "a.png")
+	waitMirror(t, conf)
+	if got := ids(); len(got) != 0 {
+		t.Errorf("queue after new put = %v, want empty", got)
+	}
+}
+
+func TestProcessMirrorDeadline(t *testing.T) {
+	conf, primary, cleanup := newTestLocal(t)
+	defer cleanup()
+	if err := primary.Put("a.png", strings.NewReader("a")); err != nil {
+		t.Fatal(err)
+	}
+	mirrorMu.Lock()
+	_ = saveMirror(conf, []*mirrorJob{{ID: "1", Source: "Local", Target: "Backup", Operate: "put", Key: "a.png"}})
+	mirrorMu.Unlock()
+	//超过时间的任务不执行 保留在队列中等待首次复制
+	processMirror(conf, time.Now().Add(-time.Second), func(job *mirrorJob) bool { return true })
+	mirrorMu.Lock()
+	queue := loadMirror(conf)
+	var running = len(mirrorRunning)
+	mirrorMu.Unlock()
+	if len(queue) != 1 || queue[0].Attempts != 0 || running != 0 {
+		t.Errorf("queue = %+v, running = %d", queue, running)
+	}
+	//未启动后台复制时 flushMirror在返回前完成复制
+	flushMirror(conf)
+	mirrorMu.Lock()
+	queue = loadMirror(conf)
+	mirrorMu.Unlock()
+	if len(queue) != 0 {
+		t.Errorf("queue after flush = %+v, want empty", queue)
+	}
+}
+
+// waitMirror 等待后台复制完成首次尝试
+func waitMirror(t *testing.T, conf *config.Config) {
+	var deadline = time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		mirrorMu.Lock()
+		var pending bool
+		for _, job := range loadMirror(conf) {
+			if job.Attempts == 0 || mirrorRunning[job.ID] {
+				pending = true
+			}
+		}
+		mirrorMu.Unlock()
+		if !pending {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("mirror jobs did not finish")
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"Pines/config"
+	"Pines/storage"
+)
+
+// 分片上传请求参数信息
+// Operate: 操作类型 [initiate,uploadpart,listparts,complete,abort]
+// Path: 上传的目标绝对地址
+// UploadID: initiate返回的上传ID
+// Part: 分片编号 从1开始
+// uploadpart的请求体为分片内容 complete的请求体为可选的分片列表 为空时合并所有已上传的分片
+
+const (
+	// maxPartNumber 分片编号的上限 与Cos/Oss/S3一致
+	maxPartNumber = 10000
+)
+
+// multipart 处理分片上传相关的操作
+func multipart(w http.ResponseWriter, r *http.Request, store storage.Storage, operate, name string, conf *config.Config) {
+	uploader, err := storage.Multipart(store)
+	if err != nil {
+		WriteError(w, "ErrorMultipart", err)
+		return
+	}
+	var query = r.URL.Query()
+	var key = query.Get("path")
+	var uploadID = query.Get("uploadid")
+	if key == "" || (operate != "initiate" && uploadID == "") {
+		WriteJSON(w, &Response{
+			Code:    500,
+			Message: "ErrorMultipart:invalid path or uploadid",
+		})
+		return
+	}
+	switch operate {
+	case "initiate":
+		uploadID, err = uploader.InitiateMultipart(key)
+		if err != nil {
+			WriteError(w, "ErrorMultipart", err)
+			return
+		}
+		WriteJSON(w, &Response{
+			Code:    200,
+			Message: "ok",
+			Data:    uploadID,
+		})
+	case "uploadpart":
+		uploadPart(w, r, uploader, key, uploadID)
+	case "listparts":
+		parts, err := uploader.ListParts(key, uploadID)
+		if err != nil {
+			WriteError(w, "ErrorMultipart", err)
+			return
+		}
+		WriteJSON(w, &List{
+			Code:    200,
+			Count:   len(parts),
+			Message: "ok",
+			Data:    parts,
+		})
+	case "complete":
+		if completeMultipart(w, r, store, uploader, key, uploadID) {
+			mirror(conf, name, "put", key)
+		}
+	case "abort":
+		if err = uploader.AbortMultipart(key, uploadID); err != nil {
+			WriteError(w, "ErrorMultipart", err)
+			return
+		}
+		WriteJSON(w, &Response{
+			Code:    200,
+			Message: "ok",
+		})
+	}
+}
+
+// uploadPart 上传一个分片 请求体为分片内容 需要携带Content-Length 且不能超过分片大小上限
+func uploadPart(w http.ResponseWriter, r *http.Request, uploader storage.MultipartUploader, key, uploadID string) {
+	number, err := strconv.Atoi(r.URL.Query().Get("part"))
+	if err != nil || number <= 0 || number > maxPartNumber {
+		WriteJSON(w, &Response{
+			Code:    500,
+			Message: "ErrorMultipart:invalid part",
+		})
+		return
+	}
+	if r.ContentLength <= 0 {
+		WriteJSON(w, &Response{
+			Code:    500,
+			Message: "ErrorMultipart:content length required",
+		})
+		return
+	}
+	if r.ContentLength > storage.MaxPartSize {
+		WriteError(w, "ErrorMultipart", storage.ErrPartTooLarge)
+		return
+	}
+	part, err := uploader.UploadPart(key, uploadID, number, r.Body, r.ContentLength)
+	if err != nil {
+		WriteError(w, "ErrorMultipart", err)
+		return
+	}
+	WriteJSON(w, &Response{
+		Code:    200,
+		Message: "ok",
+		Data:    part,
+	})
+}
+
+// completeMultipart 合并分片 请求体为空时使用已上传的全部分片 返回是否合并成功
+func completeMultipart(w http.ResponseWriter, r *http.Request, store storage.Storage, uploader storage.MultipartUploader, key, uploadID string) bool {
+	var parts []storage.Part
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBody)).Decode(&parts)
+	if err == io.EOF {
+		parts, err = uploader.ListParts(key, uploadID)
+		//无法列举分片的存储服务(又拍云)在合并时不需要分片列表
+		if err == storage.ErrNotSupported {
+			err = nil
+		}
+	}
+	if err != nil {
+		WriteError(w, "ErrorMultipart", err)
+		return false
+	}
+	storage.SortParts(parts)
+	if err = uploader.CompleteMultipart(key, uploadID, parts); err != nil {
+		WriteError(w, "ErrorMultipart", err)
+		return false
+	}
+	WriteJSON(w, &Response{
+		Code:    200,
+		Message: "ok",
+		Data:    store.Domain() + key,
+	})
+	return true
+}
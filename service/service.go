@@ -0,0 +1,49 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// Response 是交付层的基本回应
+type Response struct {
+	Code    int         `json:"code"`    //请求状态代码
+	Message interface{} `json:"message"` //请求结果提示
+	Data    interface{} `json:"data"`    //请求结果与错误原因
+}
+
+// List 会返回给交付层一个列表回应
+type List struct {
+	Code    int         `json:"code"`           //请求状态代码
+	Count   int         `json:"count"`          //数据量
+	Message interface{} `json:"message"`        //请求结果提示
+	Data    interface{} `json:"data"`           //请求结果
+	Next    string      `json:"next,omitempty"` //分页列举时下一页的游标
+}
+
+// Write 输出返回结果
+func Write(w http.ResponseWriter, response []byte) {
+	//公共的响应头设置
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, PUT, OPTIONS")
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(string(response))))
+	_, _ = w.Write(response)
+	return
+}
+
+// WriteJSON 序列化v并输出
+func WriteJSON(w http.ResponseWriter, v interface{}) {
+	response, _ := json.Marshal(v)
+	Write(w, response)
+}
+
+// WriteError 输出一个失败的回应 kind为错误类型 如 ErrorUpload
+func WriteError(w http.ResponseWriter, kind string, err error) {
+	WriteJSON(w, &Response{
+		Code:    500,
+		Message: kind + ":" + err.Error(),
+	})
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"Pines/config"
+	"Pines/storage"
+)
+
+// 同步与比较请求参数信息
+// Path: 源目录(比较时为左侧目录) 以 / 结尾 为空时表示根目录
+// Dest: 目标目录(比较时为右侧目录) 为空时与源目录相同
+// Target: 目标存储服务 为空时在当前存储服务内同步
+// DryRun: 为true时只返回需要复制与删除的对象
+// Delete: 为true时删除目标目录中源目录不存在的对象
+// Concurrency: 并发数 默认为4 最大为16
+
+// syncDir 将当前存储服务的path目录同步到target存储服务的dest目录
+func syncDir(w http.ResponseWriter, r *http.Request, store storage.Storage, name string, conf *config.Config) {
+	var query = r.URL.Query()
+	target, src, dst, ok := syncTarget(w, r, store, name, conf, "ErrorSync")
+	if !ok {
+		return
+	}
+	if target == store && (strings.HasPrefix(dst, src) || strings.HasPrefix(src, dst)) {
+		WriteJSON(w, &Response{
+			Code:    500,
+			Message: "ErrorSync:invalid path or dest",
+		})
+		return
+	}
+	var opt = storage.SyncOptions{
+		DryRun: query.Get("dryrun") == "true",
+		Delete: query.Get("delete") == "true",
+	}
+	if value := query.Get("concurrency"); value != "" {
+		concurrency, err := strconv.Atoi(value)
+		if err != nil || concurrency <= 0 || concurrency > storage.MaxSyncConcurrency {
+			WriteJSON(w, &Response{
+				Code:    500,
+				Message: "ErrorSync:invalid concurrency",
+			})
+			return
+		}
+		opt.Concurrency = concurrency
+	}
+	report, err := storage.Sync(store, target, src, dst, opt)
+	if err != nil {
+		WriteError(w, "ErrorSync", err)
+		return
+	}
+	if !opt.DryRun {
+		//同步写入的是目标存储服务 按其自身的镜像配置复制
+		var targetName = query.Get("target")
+		if targetName == "" {
+			targetName = name
+		}
+		var queued = queueMirror(conf, targetName, "put", report.Copied...)
+		if queueMirror(conf, targetName, "delete", report.Deleted...) || queued {
+			flushMirror(conf)
+		}
+	}
+	var count = len(report.Copied) + len(report.Deleted)
+	if len(report.Failed) > 0 {
+		WriteJSON(w, &List{
+			Code:    500,
+			Count:   count,
+			Message: "ErrorSync:" + strconv.Itoa(len(report.Failed)) + " objects failed",
+			Data:    report,
+		})
+		return
+	}
+	WriteJSON(w, &List{
+		Code:    200,
+		Count:   count,
+		Message: "ok",
+		Data:    report,
+	})
+}
+
+// diffDir 比较当前存储服务的path目录与target存储服务的dest目录 参数与同步一致
+func diffDir(w http.ResponseWriter, r *http.Request, store storage.Storage, name string, conf *config.Config) {
+	target, left, right, ok := syncTarget(w, r, store, name, conf, "ErrorDiff")
+	if !ok {
+		return
+	}
+	report, err := storage.Diff(store, target, left, right)
+	if err != nil {
+		WriteError(w, "ErrorDiff", err)
+		return
+	}
+	WriteJSON(w, &List{
+		Code:    200,
+		Count:   report.Count(),
+		Message: "ok",
+		Data:    report,
+	})
+}
+
+// syncTarget 解析同步与比较操作的目标存储服务、源目录与目标目录 参数无效时输出kind类型的错误
+func syncTarget(w http.ResponseWriter, r *http.Request, store storage.Storage, name string, conf *config.Config, kind string) (storage.Storage, string, string, bool) {
+	var query = r.URL.Query()
+	var src = query.Get("path")
+	var dst = query.Get("dest")
+	if dst == "" {
+		dst = src
+	}
+	if !validSyncDir(src) || !validSyncDir(dst) {
+		WriteJSON(w, &Response{
+			Code:    500,
+			Message: kind + ":invalid path or dest",
+		})
+		return nil, "", "", false
+	}
+	var target = store
+	if t := query.Get("target"); t != "" && t != name {
+		var err error
+		if target, err = storage.New(t, conf); err != nil {
+			WriteError(w, "ErrorInitClient", err)
+			return nil, "", "", false
+		}
+	}
+	return target, src, dst, true
+}
+
+// validSyncDir 同步的目录为空(根目录)或以 / 结尾
+func validSyncDir(dir string) bool {
+	return dir == "" || strings.HasSuffix(dir, "/")
+}
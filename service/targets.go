@@ -0,0 +1,52 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"Pines/config"
+)
+
+// targetInfo 可以切换的存储目标
+type targetInfo struct {
+	Name string `json:"name"` //通过 /api/storage?storage=<name> 访问
+	Type string `json:"type"` //存储服务类型
+	API  string `json:"api"`  //接口路径 前端按 /api/<api> 访问 规则 cos 或 storage/blog
+}
+
+// Targets 列出已配置的存储服务与命名的存储目标 供前端切换
+func Targets(w http.ResponseWriter, r *http.Request) {
+	if Preflight(w, r) {
+		return
+	}
+	var conf = config.GetConfig()
+	if !Authorized(r, conf) {
+		Unauthorized(w)
+		return
+	}
+	var targets []targetInfo
+	//未填写配置的存储服务不会列出
+	var configured = map[string]bool{
+		"Cos":   conf.Cos != config.Cos{},
+		"Oss":   conf.Oss != config.Oss{},
+		"Ups":   conf.Ups != config.Ups{},
+		"S3":    conf.S3 != config.S3{},
+		"Qiniu": conf.Qiniu != config.Qiniu{},
+		"Local": conf.Local != config.Local{},
+	}
+	for _, name := range []string{"Cos", "Oss", "Ups", "S3", "Qiniu", "Local"} {
+		if configured[name] {
+			targets = append(targets, targetInfo{Name: name, Type: name, API: strings.ToLower(name)})
+		}
+	}
+	for _, target := range conf.Targets {
+		targets = append(targets, targetInfo{Name: target.Name, Type: target.Type, API: "storage/" + url.PathEscape(target.Name)})
+	}
+	WriteJSON(w, &List{
+		Code:    200,
+		Count:   len(targets),
+		Message: conf.Default,
+		Data:    targets,
+	})
+}
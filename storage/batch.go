@@ -0,0 +1,194 @@
+package storage
+
+// Failure 批量操作中失败的对象
+type Failure struct {
+	Key   string `json:"key"`   //对象的绝对路径
+	Error string `json:"error"` //失败原因
+}
+
+// Result 批量操作的结果 部分对象失败时不会中断整个操作
+type Result struct {
+	Done   []string  `json:"done"`   //操作成功的对象
+	Failed []Failure `json:"failed"` //操作失败的对象
+}
+
+// DirMover 可以直接移动整个目录的存储驱动 如本地存储
+type DirMover interface {
+	MoveDir(src, dst string) error
+}
+
+// BatchDeleter 支持批量删除的存储驱动 单次最多删除 MaxBatchDelete 个对象
+type BatchDeleter interface {
+	DeleteMulti(keys []string) *Result
+}
+
+// MaxBatchDelete 批量删除接口单次能删除的最大对象数量 与Cos/Oss/S3的上限一致
+const MaxBatchDelete = 1000
+
+// ok 记录操作成功的对象
+func (r *Result) ok(key string) {
+	r.Done = append(r.Done, key)
+}
+
+// fail 记录操作失败的对象
+func (r *Result) fail(key string, err error) {
+	r.Failed = append(r.Failed, Failure{Key: key, Error: err.Error()})
+}
+
+// Merge 合并另一个批量操作的结果
+func (r *Result) Merge(other *Result) {
+	r.Done = append(r.Done, other.Done...)
+	r.Failed = append(r.Failed, other.Failed...)
+}
+
+// DeleteMulti 删除多个对象 支持批量删除的存储驱动会分批调用批量删除接口 其余逐个删除
+func DeleteMulti(store Storage, keys []string) *Result {
+	var result = new(Result)
+	if deleter, ok := store.(BatchDeleter); ok {
+		for len(keys) > 0 {
+			var n = len(keys)
+			if n > MaxBatchDelete {
+				n = MaxBatchDelete
+			}
+			result.Merge(deleter.DeleteMulti(keys[:n]))
+			keys = keys[n:]
+		}
+		return result
+	}
+	for _, key := range keys {
+		if err := store.Delete(key); err != nil {
+			result.fail(key, err)
+			continue
+		}
+		result.ok(key)
+	}
+	return result
+}
+
+// DeleteDir 递归删除prefix目录下的所有对象 最后由内向外删除目录本身
+func DeleteDir(store Storage, prefix string) *Result {
+	var result = new(Result)
+	files, dirs, err := listTree(store, prefix)
+	if err != nil {
+		result.fail(prefix, err)
+		return result
+	}
+	result.Merge(DeleteMulti(store, files))
+	//对象存储中的目录占位对象可能并不存在 删除失败后目录仍然存在时才视为错误
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := store.Delete(dirs[i]); err != nil {
+			if _, statErr := store.Stat(dirs[i]); statErr == nil {
+				result.fail(dirs[i], err)
+			}
+		}
+	}
+	return result
+}
+
+// listTree 递归列举prefix目录下的所有文件与目录 目录按由外向内的顺序返回 包含prefix本身
+func listTree(store Storage, prefix string) (files, dirs []string, err error) {
+	objects, err := ListAll(store, prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	dirs = append(dirs, prefix)
+	for _, obj := range objects {
+		if !obj.IsDir {
+			files = append(files, obj.Key())
+			continue
+		}
+		subFiles, subDirs, err := listTree(store, obj.Key())
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, subFiles...)
+		dirs = append(dirs, subDirs...)
+	}
+	return files, dirs, nil
+}
+
+// MoveDir 移动src目录到dst目录 两者均以 / 结尾
+// 目录下的对象逐个移动 移动完成后删除源目录
+func MoveDir(store Storage, src, dst string) *Result {
+	var result = new(Result)
+	if mover, ok := store.(DirMover); ok {
+		if err := mover.MoveDir(src, dst); err != nil {
+			result.fail(src, err)
+		} else {
+			result.ok(src)
+		}
+		return result
+	}
+	dirs := walkDir(store, src, dst, result, store.Mkdir, store.Move)
+	//由内向外删除源目录 目录中仍有移动失败的对象时会删除失败 此处忽略该错误
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = store.Delete(dirs[i])
+	}
+	return result
+}
+
+// CopyDir 在同一存储桶内复制src目录到dst目录 两者均以 / 结尾
+func CopyDir(store Storage, src, dst string) *Result {
+	var result = new(Result)
+	walkDir(store, src, dst, result, store.Mkdir, store.Copy)
+	return result
+}
+
+// Transfer 将from中的src对象以流的方式复制到to中的dst 用于不同存储服务之间的复制
+// 先获取源对象的大小 使目标存储服务可以一次上传小文件
+func Transfer(from, to Storage, src, dst string) error {
+	var size int64 = -1
+	if info, err := from.Stat(src); err == nil && !info.IsDir {
+		size = info.Size
+	}
+	return transfer(from, to, src, dst, size)
+}
+
+// transfer 以流的方式复制对象 size为源对象的大小 不大于0时视为未知
+func transfer(from, to Storage, src, dst string, size int64) error {
+	reader, err := from.Get(src)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if size <= 0 {
+		return to.Put(dst, reader)
+	}
+	return to.Put(dst, &sizedReader{Reader: reader, size: size})
+}
+
+// TransferDir 将from中的src目录以流的方式复制到to中的dst目录 两者均以 / 结尾
+func TransferDir(from, to Storage, src, dst string) *Result {
+	var result = new(Result)
+	walkDir(from, src, dst, result, to.Mkdir, func(src, dst string) error {
+		return Transfer(from, to, src, dst)
+	})
+	return result
+}
+
+// walkDir 递归遍历src目录 对其中的每个对象调用fn 目标目录由mkdir先于其中的对象创建
+// 返回遍历过的源目录 外层目录在前
+func walkDir(store Storage, src, dst string, result *Result, mkdir func(dst string) error, fn func(src, dst string) error) []string {
+	if err := mkdir(dst); err != nil {
+		result.fail(dst, err)
+		return nil
+	}
+	objects, err := ListAll(store, src)
+	if err != nil {
+		result.fail(src, err)
+		return nil
+	}
+	var dirs = []string{src}
+	for _, obj := range objects {
+		if obj.IsDir {
+			dirs = append(dirs, walkDir(store, obj.Key(), dst+obj.Filename, result, mkdir, fn)...)
+			continue
+		}
+		if err := fn(obj.Key(), dst+obj.Filename); err != nil {
+			result.fail(obj.Key(), err)
+			continue
+		}
+		result.ok(obj.Key())
+	}
+	return dirs
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// deleteLog 记录删除的顺序 failKey中的对象删除失败
+type deleteLog struct {
+	Storage
+	deleted []string
+	failKey string
+}
+
+// Delete 记录删除的对象
+func (d *deleteLog) Delete(key string) error {
+	d.deleted = append(d.deleted, key)
+	if key == d.failKey {
+		return errors.New("ErrorDelete: denied")
+	}
+	return d.Storage.Delete(key)
+}
+
+// noDir 模拟没有目录占位对象的对象存储 删除与查看目录都会失败
+type noDir struct {
+	Storage
+}
+
+// Delete 删除目录时返回错误
+func (n noDir) Delete(key string) error {
+	if strings.HasSuffix(key, "/") {
+		return os.ErrNotExist
+	}
+	return n.Storage.Delete(key)
+}
+
+// Stat 查看目录时返回错误
+func (n noDir) Stat(key string) (*ObjectInfo, error) {
+	if strings.HasSuffix(key, "/") {
+		return nil, os.ErrNotExist
+	}
+	return n.Storage.Stat(key)
+}
+
+var batchFiles = map[string]string{
+	"src/a.txt":       "a",
+	"src/sub/b.txt":   "b",
+	"src/sub/c/d.txt": "d",
+	"other.txt":       "o",
+}
+
+// readTree 递归读取prefix目录下的所有文件 返回相对路径与内容
+func readTree(t *testing.T, store Storage, prefix string) map[string]string {
+	files, _, err := listTree(store, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tree = make(map[string]string)
+	for _, key := range files {
+		reader, err := store.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(reader)
+		_ = reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree[strings.TrimPrefix(key, prefix)] = string(data)
+	}
+	return tree
+}
+
+var srcTree = map[string]string{"a.txt": "a", "sub/b.txt": "b", "sub/c/d.txt": "d"}
+
+func TestDeleteDir(t *testing.T) {
+	local, cleanup := newTestLocal(t)
+	defer cleanup()
+	putTestFiles(t, local, batchFiles)
+	var store = &deleteLog{Storage: local}
+	result := DeleteDir(store, "src/")
+	if len(result.Failed) != 0 || len(result.Done) != 3 {
+		t.Fatalf("DeleteDir = %+v", result)
+	}
+	//文件删除后由内向外删除目录
+	var dirs []string
+	for _, key := range store.deleted {
+		if strings.HasSuffix(key, "/") {
+			dirs = append(dirs, key)
+		}
+	}
+	if want := []string{"src/sub/c/", "src/sub/", "src/"}; !reflect.DeepEqual(dirs, want) {
+		t.Errorf("deleted dirs = %v, want %v", dirs, want)
+	}
+	if _, err := local.Stat("src/"); err == nil {
+		t.Error("src/ still exists")
+	}
+	if _, err := local.Stat("other.txt"); err != nil {
+		t.Error("other.txt was deleted:", err)
+	}
+}
+
+func TestDeleteDirFailed(t *testing.T) {
+	local, cleanup := newTestLocal(t)
+	defer cleanup()
+	putTestFiles(t, local, batchFiles)
+	//文件删除失败时 其所在的目录仍然存在 需要记录为失败
+	result := DeleteDir(&deleteLog{Storage: local, failKey: "src/sub/b.txt"}, "src/")
+	var failed []string
+	for _, failure := range result.Failed {
+		failed = append(failed, failure.Key)
+	}
+	sort.Strings(failed)
+	if want := []string{"src/", "src/sub/", "src/sub/b.txt"}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("failed = %v, want %v", failed, want)
+	}
+	//目录占位对象不存在时 删除目录失败不视为错误
+	putTestFiles(t, local, batchFiles)
+	if result = DeleteDir(noDir{local}, "src/"); len(result.Failed) != 0 {
+		t.Errorf("DeleteDir without placeholders = %+v", result.Failed)
+	}
+}
+
+func TestMoveDir(t *testing.T) {
+	local, cleanup := newTestLocal(t)
+	defer cleanup()
+	//隐藏本地存储的MoveDir 按对象逐个移动
+	for _, store := range []Storage{local, noDir{local}} {
+		putTestFiles(t, local, batchFiles)
+		result := MoveDir(store, "src/", "dst/")
+		if len(result.Failed) != 0 {
+			t.Fatalf("MoveDir = %+v", result.Failed)
+		}
+		if tree := readTree(t, local, "dst/"); !reflect.DeepEqual(tree, srcTree) {
+			t.Errorf("dst = %v, want %v", tree, srcTree)
+		}
+		if tree := readTree(t, local, "src/"); len(tree) != 0 {
+			t.Errorf("src = %v, want empty", tree)
+		}
+		DeleteDir(local, "dst/")
+	}
+}
+
+func TestCopyDir(t *testing.T) {
+	local, cleanup := newTestLocal(t)
+	defer cleanup()
+	putTestFiles(t, local, batchFiles)
+	result := CopyDir(local, "src/", "dst/")
+	if len(result.Failed) != 0 || len(result.Done) != 3 {
+		t.Fatalf("CopyDir = %+v", result)
+	}
+	for _, prefix := range []string{"src/", "dst/"} {
+		if tree := readTree(t, local, prefix); !reflect.DeepEqual(tree, srcTree) {
+			t.Errorf("%s = %v, want %v", prefix, tree, srcTree)
+		}
+	}
+}
+
+func TestTransferDir(t *testing.T) {
+	from, cleanFrom := newTestLocal(t)
+	defer cleanFrom()
+	to, cleanTo := newTestLocal(t)
+	defer cleanTo()
+	putTestFiles(t, from, batchFiles)
+	result := TransferDir(from, to, "src/", "backup/src/")
+	if len(result.Failed) != 0 || len(result.Done) != 3 {
+		t.Fatalf("TransferDir = %+v", result)
+	}
+	if tree := readTree(t, to, "backup/src/"); !reflect.DeepEqual(tree, srcTree) {
+		t.Errorf("backup/src = %v, want %v", tree, srcTree)
+	}
+	if _, err := to.Stat("other.txt"); err == nil {
+		t.Error("other.txt was transferred")
+	}
+}
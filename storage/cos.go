@@ -0,0 +1,274 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tencentyun/cos-go-sdk-v5"
+
+	"Pines/config"
+)
+
+// Cos 腾讯云Cos服务
+type Cos struct {
+	conf   config.Cos
+	client *cos.Client
+}
+
+func init() {
+	Register("Cos", NewCos)
+}
+
+// NewCos 初始化腾讯云Cos客户端
+func NewCos(conf *config.Config) (Storage, error) {
+	var c = conf.Cos
+	c.APIAddress = fmt.Sprintf("https://%s.cos.%s.myqcloud.com", c.Bucket, c.Region)
+	u, err := url.Parse(c.APIAddress)
+	if err != nil {
+		return nil, err
+	}
+	b := &cos.BaseURL{BucketURL: u}
+	client := cos.NewClient(b, &http.Client{
+		//设置超时时间
+		Timeout: 100 * time.Second,
+		Transport: &cos.AuthorizationTransport{
+			//如实填写账号和密钥，也可以设置为环境变量
+			SecretID:  c.SecretID,
+			SecretKey: c.SecretKey,
+		},
+	})
+	return &Cos{conf: c, client: client}, nil
+}
+
+// List 分页列举当前目录下的文件 游标为Cos的NextMarker
+func (c *Cos) List(prefix, marker string, limit int) (*Page, error) {
+	if marker == "" {
+		marker = prefix
+	}
+	//设置筛选器
+	opt := &cos.BucketGetOptions{
+		Prefix:    prefix,
+		Delimiter: "/",
+		Marker:    marker,
+		MaxKeys:   limit,
+	}
+	v, _, err := c.client.Bucket.Get(context.Background(), opt)
+	if err != nil {
+		return nil, err
+	}
+	var page = new(Page)
+	for _, dirname := range v.CommonPrefixes {
+		page.Objects = append(page.Objects, ListObject{
+			Filename:   strings.Replace(dirname, prefix, "", 1),
+			CreateTime: "",
+			IsDir:      true,
+			Prefix:     prefix,
+		})
+	}
+	for _, obj := range v.Contents {
+		page.Objects = append(page.Objects, ListObject{
+			Filename:   strings.Replace(obj.Key, prefix, "", 1),
+			CreateTime: obj.LastModified,
+			IsDir:      false,
+			Prefix:     prefix,
+			Size:       obj.Size,
+			ETag:       obj.ETag,
+		})
+	}
+	//单次最多返回1000条 需要根据NextMarker继续列举
+	if v.IsTruncated {
+		page.Next = v.NextMarker
+	}
+	return page, nil
+}
+
+// Delete 删除对象
+func (c *Cos) Delete(path string) error {
+	_, err := c.client.Object.Delete(context.Background(), path)
+	return err
+}
+
+// Put 上传对象
+func (c *Cos) Put(key string, reader io.Reader) error {
+	_, err := c.client.Object.Put(context.Background(), key, reader, nil)
+	return err
+}
+
+// DeleteMulti 批量删除对象
+func (c *Cos) DeleteMulti(keys []string) *Result {
+	var result = new(Result)
+	var opt = &cos.ObjectDeleteMultiOptions{Quiet: true}
+	for _, key := range keys {
+		opt.Objects = append(opt.Objects, cos.Object{Key: key})
+	}
+	res, _, err := c.client.Object.DeleteMulti(context.Background(), opt)
+	if err != nil {
+		for _, key := range keys {
+			result.fail(key, err)
+		}
+		return result
+	}
+	//Quiet模式下只返回删除失败的对象
+	var failed = make(map[string]bool)
+	for _, e := range res.Errors {
+		failed[e.Key] = true
+		result.fail(e.Key, errors.New(e.Code+":"+e.Message))
+	}
+	for _, key := range keys {
+		if !failed[key] {
+			result.ok(key)
+		}
+	}
+	return result
+}
+
+// Mkdir 创建目录
+func (c *Cos) Mkdir(path string) error {
+	_, err := c.client.Object.Put(context.Background(), path, strings.NewReader(""), nil)
+	return err
+}
+
+// Get 读取对象内容
+func (c *Cos) Get(path string) (io.ReadCloser, error) {
+	resp, err := c.client.Object.Get(context.Background(), path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// GetRange 从offset处开始读取对象内容
+func (c *Cos) GetRange(path string, offset int64) (io.ReadCloser, error) {
+	resp, err := c.client.Object.Get(context.Background(), path, &cos.ObjectGetOptions{
+		Range: fmt.Sprintf("bytes=%d-", offset),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Move 移动对象 使用服务端复制后删除源对象
+func (c *Cos) Move(src, dst string) error {
+	if err := c.Copy(src, dst); err != nil {
+		return err
+	}
+	return c.Delete(src)
+}
+
+// Copy 服务端复制对象
+func (c *Cos) Copy(src, dst string) error {
+	var source = strings.TrimPrefix(c.conf.APIAddress, "https://") + "/" + src
+	_, _, err := c.client.Object.Copy(context.Background(), dst, source, nil)
+	return err
+}
+
+// Stat 获取对象元信息
+func (c *Cos) Stat(path string) (*ObjectInfo, error) {
+	resp, err := c.client.Object.Head(context.Background(), path, nil)
+	if err != nil {
+		return nil, err
+	}
+	info := &ObjectInfo{
+		Key:   path,
+		IsDir: strings.HasSuffix(path, "/"),
+	}
+	parseHeader(info, resp.Header, "cos")
+	if info.StorageClass == "" {
+		//标准存储不会返回存储类型
+		info.StorageClass = "STANDARD"
+	}
+	return info, nil
+}
+
+// Sign 生成预签名的下载地址
+func (c *Cos) Sign(path string, expire time.Duration) (string, error) {
+	u, err := c.client.Object.GetPresignedURL(context.Background(), http.MethodGet, path, c.conf.SecretID, c.conf.SecretKey, expire, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// PresignUpload 生成预签名的PUT上传地址
+func (c *Cos) PresignUpload(key string, expire time.Duration) (*UploadPolicy, error) {
+	u, err := c.client.Object.GetPresignedURL(context.Background(), http.MethodPut, key, c.conf.SecretID, c.conf.SecretKey, expire, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &UploadPolicy{
+		Method: http.MethodPut,
+		URL:    u.String(),
+		Key:    key,
+		Expire: time.Now().Add(expire),
+	}, nil
+}
+
+// InitiateMultipart 初始化分片上传
+func (c *Cos) InitiateMultipart(key string) (string, error) {
+	res, _, err := c.client.Object.InitiateMultipartUpload(context.Background(), key, nil)
+	if err != nil {
+		return "", err
+	}
+	return res.UploadID, nil
+}
+
+// UploadPart 上传分片
+func (c *Cos) UploadPart(key, uploadID string, number int, reader io.Reader, size int64) (*Part, error) {
+	resp, err := c.client.Object.UploadPart(context.Background(), key, uploadID, number, reader, &cos.ObjectUploadPartOptions{
+		ContentLength: int(size),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Part{Number: number, ETag: resp.Header.Get("ETag"), Size: size}, nil
+}
+
+// ListParts 列举已经上传的分片
+func (c *Cos) ListParts(key, uploadID string) ([]Part, error) {
+	var parts []Part
+	var opt = new(cos.ObjectListPartsOptions)
+	for {
+		res, _, err := c.client.Object.ListParts(context.Background(), key, uploadID, opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range res.Parts {
+			parts = append(parts, Part{Number: part.PartNumber, ETag: part.ETag, Size: int64(part.Size)})
+		}
+		if !res.IsTruncated {
+			return parts, nil
+		}
+		opt.PartNumberMarker = res.NextPartNumberMarker
+	}
+}
+
+// CompleteMultipart 完成分片上传
+func (c *Cos) CompleteMultipart(key, uploadID string, parts []Part) error {
+	var opt = new(cos.CompleteMultipartUploadOptions)
+	for _, part := range parts {
+		opt.Parts = append(opt.Parts, cos.Object{PartNumber: part.Number, ETag: part.ETag})
+	}
+	_, _, err := c.client.Object.CompleteMultipartUpload(context.Background(), key, uploadID, opt)
+	return err
+}
+
+// AbortMultipart 取消分片上传
+func (c *Cos) AbortMultipart(key, uploadID string) error {
+	_, err := c.client.Object.AbortMultipartUpload(context.Background(), key, uploadID)
+	return err
+}
+
+// Domain 访问域名 未设置自定义域名时使用API地址
+func (c *Cos) Domain() string {
+	if c.conf.Domain == "" {
+		return c.conf.APIAddress + "/"
+	}
+	return c.conf.Domain
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+)
+
+// DiffReport 两个目录的差异 路径均为相对目录的路径
+type DiffReport struct {
+	OnlyLeft     []string `json:"only_left"`     //只存在于左侧的对象
+	OnlyRight    []string `json:"only_right"`    //只存在于右侧的对象
+	SizeMismatch []string `json:"size_mismatch"` //大小不同的对象
+	HashMismatch []string `json:"hash_mismatch"` //大小相同但MD5不同的对象
+	Same         int      `json:"same"`          //大小与MD5均相同的对象数量
+	Unverified   int      `json:"unverified"`    //大小相同但至少一侧没有MD5 无法比较内容的对象数量
+}
+
+// Count 存在差异的对象数量
+func (d *DiffReport) Count() int {
+	return len(d.OnlyLeft) + len(d.OnlyRight) + len(d.SizeMismatch) + len(d.HashMismatch)
+}
+
+// Diff 比较left中的lp目录与right中的rp目录 两者均以 / 结尾或为空
+// 按相对路径匹配对象 两侧的ETag均为MD5时比较内容
+func Diff(left, right Storage, lp, rp string) (*DiffReport, error) {
+	leftFiles, err := relativeFiles(left, lp)
+	if err != nil {
+		return nil, err
+	}
+	rightFiles, err := relativeFiles(right, rp)
+	if err != nil {
+		return nil, err
+	}
+	var report = new(DiffReport)
+	for name, l := range leftFiles {
+		r, ok := rightFiles[name]
+		switch {
+		case !ok:
+			report.OnlyLeft = append(report.OnlyLeft, name)
+		case l.Bytes() != r.Bytes():
+			report.SizeMismatch = append(report.SizeMismatch, name)
+		case l.MD5() == "" || r.MD5() == "":
+			report.Unverified++
+		case l.MD5() != r.MD5():
+			report.HashMismatch = append(report.HashMismatch, name)
+		default:
+			report.Same++
+		}
+	}
+	for name := range rightFiles {
+		if _, ok := leftFiles[name]; !ok {
+			report.OnlyRight = append(report.OnlyRight, name)
+		}
+	}
+	sort.Strings(report.OnlyLeft)
+	sort.Strings(report.OnlyRight)
+	sort.Strings(report.SizeMismatch)
+	sort.Strings(report.HashMismatch)
+	return report, nil
+}
+
+// relativeFiles 递归列举prefix目录下的所有文件 以相对prefix的路径为键
+func relativeFiles(store Storage, prefix string) (map[string]ListObject, error) {
+	files, err := ListFiles(store, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var result = make(map[string]ListObject, len(files))
+	for _, obj := range files {
+		result[strings.TrimPrefix(obj.Key(), prefix)] = obj
+	}
+	return result, nil
+}
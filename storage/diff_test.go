@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// etagStore 在列举结果中填充内容的MD5 模拟Cos/Oss/S3的ETag
+type etagStore struct {
+	Storage
+}
+
+// List 列举并计算文件的MD5
+func (e etagStore) List(prefix, marker string, limit int) (*Page, error) {
+	page, err := e.Storage.List(prefix, marker, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i, obj := range page.Objects {
+		if obj.IsDir {
+			continue
+		}
+		reader, err := e.Get(obj.Key())
+		if err != nil {
+			return nil, err
+		}
+		hash := md5.New()
+		_, err = io.Copy(hash, reader)
+		_ = reader.Close()
+		if err != nil {
+			return nil, err
+		}
+		page.Objects[i].ETag = "\"" + hex.EncodeToString(hash.Sum(nil)) + "\""
+	}
+	return page, nil
+}
+
+func TestDiff(t *testing.T) {
+	left, cleanLeft := newTestLocal(t)
+	defer cleanLeft()
+	right, cleanRight := newTestLocal(t)
+	defer cleanRight()
+	putTestFiles(t, left, map[string]string{
+		"a.txt":     "a",
+		"img/b.png": "bb",
+		"img/c.png": "c",
+		"img/e.png": "e",
+		"f.txt":     "f",
+	})
+	putTestFiles(t, right, map[string]string{
+		"img/b.png": "b",
+		"img/c.png": "c",
+		"img/e.png": "x",
+		"d.txt":     "d",
+		"f.txt":     "f",
+	})
+	var tests = []struct {
+		name        string
+		left, right Storage
+		want        DiffReport
+	}{
+		{"without md5", left, right, DiffReport{
+			OnlyLeft:     []string{"a.txt"},
+			OnlyRight:    []string{"d.txt"},
+			SizeMismatch: []string{"img/b.png"},
+			Unverified:   3,
+		}},
+		{"one side md5", etagStore{left}, right, DiffReport{
+			OnlyLeft:     []string{"a.txt"},
+			OnlyRight:    []string{"d.txt"},
+			SizeMismatch: []string{"img/b.png"},
+			Unverified:   3,
+		}},
+		{"both md5", etagStore{left}, etagStore{right}, DiffReport{
+			OnlyLeft:     []string{"a.txt"},
+			OnlyRight:    []string{"d.txt"},
+			SizeMismatch: []string{"img/b.png"},
+			HashMismatch: []string{"img/e.png"},
+			Same:         2,
+		}},
+	}
+	for _, test := range tests {
+		report, err := Diff(test.left, test.right, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(*report, test.want) {
+			t.Errorf("%s: Diff = %+v, want %+v", test.name, *report, test.want)
+		}
+	}
+}
+
+func TestDiffPrefix(t *testing.T) {
+	store, cleanup := newTestLocal(t)
+	defer cleanup()
+	putTestFiles(t, store, map[string]string{
+		"v1/a.txt":     "a",
+		"v1/sub/b.txt": "b",
+		"v2/a.txt":     "a",
+		"v2/c.txt":     "c",
+	})
+	report, err := Diff(store, store, "v1/", "v2/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = DiffReport{OnlyLeft: []string{"sub/b.txt"}, OnlyRight: []string{"c.txt"}, Unverified: 1}
+	if !reflect.DeepEqual(*report, want) || report.Count() != 2 {
+		t.Errorf("Diff = %+v, want %+v", *report, want)
+	}
+}
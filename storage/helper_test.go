@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"Pines/config"
+)
+
+// newTestLocal 在临时目录中创建本地存储 返回清理函数
+func newTestLocal(t *testing.T) (Storage, func()) {
+	root, err := ioutil.TempDir("", "pines-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewLocal(&config.Config{Local: config.Local{Root: root}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, func() {
+		_ = os.RemoveAll(root)
+	}
+}
+
+// putTestFiles 写入测试文件 files为路径与内容
+func putTestFiles(t *testing.T, store Storage, files map[string]string) {
+	for key, content := range files {
+		if err := store.Put(key, strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
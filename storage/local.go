@@ -0,0 +1,427 @@
+package storage
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"Pines/config"
+)
+
+const (
+	// LocalRoute 独立运行模式下本地存储文件的访问路由
+	LocalRoute = "/local/"
+	// localListLimit 本地存储单次列举的默认数量
+	localListLimit = 1000
+	// LocalDefaultRoot 未设置根目录时使用的存储目录
+	LocalDefaultRoot = "data"
+	// localMultipartDir 分片上传的临时目录 位于系统临时目录下 避免出现在列举结果中
+	localMultipartDir = "pines-multipart"
+)
+
+var (
+	// errUploadID 无效的分片上传ID
+	errUploadID = errors.New("invalid upload id")
+	// errLocalNotConfigured 未配置本地存储 此时不提供 /local/ 路由 上传的文件无法访问
+	errLocalNotConfigured = errors.New("local storage is not configured")
+)
+
+// Local 本地文件系统存储 将磁盘上的目录映射为存储桶
+type Local struct {
+	conf config.Local
+}
+
+func init() {
+	Register("Local", NewLocal)
+}
+
+// NewLocal 初始化本地存储 根目录不存在时会自动创建 未配置本地存储时返回errLocalNotConfigured
+func NewLocal(conf *config.Config) (Storage, error) {
+	var l = conf.Local
+	//与NewLocalServer的判断一致 未配置时返回的访问地址均不可用
+	if l == (config.Local{}) {
+		return nil, errLocalNotConfigured
+	}
+	if l.Root == "" {
+		l.Root = LocalDefaultRoot
+	}
+	if l.Domain == "" {
+		l.Domain = LocalRoute
+	}
+	if err := os.MkdirAll(l.Root, 0755); err != nil {
+		return nil, err
+	}
+	return &Local{conf: l}, nil
+}
+
+// List 分页列举当前目录下的文件 游标为上一页最后一个文件名
+func (l *Local) List(prefix, marker string, limit int) (*Page, error) {
+	if limit <= 0 {
+		limit = localListLimit
+	}
+	var page = new(Page)
+	infos, err := ioutil.ReadDir(l.abs(prefix))
+	if os.IsNotExist(err) {
+		//与云存储一致 不存在的目录视为空目录
+		return page, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var last string
+	for _, info := range infos {
+		//ReadDir的结果按文件名排序
+		if marker != "" && info.Name() <= marker {
+			continue
+		}
+		if len(page.Objects) == limit {
+			page.Next = last
+			break
+		}
+		last = info.Name()
+		var obj = ListObject{
+			Filename:   info.Name(),
+			Prefix:     prefix,
+			IsDir:      info.IsDir(),
+			CreateTime: info.ModTime(),
+		}
+		if info.IsDir() {
+			obj.Filename += "/"
+		} else {
+			obj.Size = info.Size()
+		}
+		page.Objects = append(page.Objects, obj)
+	}
+	return page, nil
+}
+
+// Delete 删除文件或空目录
+func (l *Local) Delete(path string) error {
+	return os.Remove(l.abs(path))
+}
+
+// Put 写入文件 父目录不存在时会自动创建
+func (l *Local) Put(key string, reader io.Reader) error {
+	var name = l.abs(key)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(file, reader); err != nil {
+		//与云存储一致 上传失败时不保留不完整的文件
+		_ = file.Close()
+		_ = os.Remove(name)
+		return err
+	}
+	return file.Close()
+}
+
+// Mkdir 创建目录
+func (l *Local) Mkdir(path string) error {
+	return os.MkdirAll(l.abs(path), 0755)
+}
+
+// Get 读取文件内容
+func (l *Local) Get(path string) (io.ReadCloser, error) {
+	return os.Open(l.abs(path))
+}
+
+// GetRange 从offset处开始读取文件内容
+func (l *Local) GetRange(path string, offset int64) (io.ReadCloser, error) {
+	file, err := os.Open(l.abs(path))
+	if err != nil {
+		return nil, err
+	}
+	if _, err = file.Seek(offset, io.SeekStart); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// Copy 复制文件
+func (l *Local) Copy(src, dst string) error {
+	file, err := l.Get(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return l.Put(dst, file)
+}
+
+// Move 移动文件或目录 目录会被整体移动
+func (l *Local) Move(src, dst string) error {
+	var name = l.abs(dst)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	return os.Rename(l.abs(src), name)
+}
+
+// MoveDir 移动目录 本地存储可以直接重命名整个目录
+func (l *Local) MoveDir(src, dst string) error {
+	return l.Move(src, dst)
+}
+
+// Stat 获取文件元信息
+func (l *Local) Stat(path string) (*ObjectInfo, error) {
+	info, err := os.Stat(l.abs(path))
+	if err != nil {
+		return nil, err
+	}
+	var obj = &ObjectInfo{
+		Key:          path,
+		IsDir:        info.IsDir(),
+		Size:         info.Size(),
+		LastModified: info.ModTime(),
+	}
+	if info.IsDir() {
+		return obj, nil
+	}
+	obj.ContentType = mime.TypeByExtension(filepath.Ext(path))
+	//下载时每个Range请求都会调用Stat 使用修改时间与大小作为ETag 避免每次计算整个文件的MD5
+	obj.ETag = fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size())
+	return obj, nil
+}
+
+// Sign 本地存储的文件均可直接访问 返回普通地址
+func (l *Local) Sign(path string, expire time.Duration) (string, error) {
+	return l.conf.Domain + path, nil
+}
+
+// InitiateMultipart 初始化分片上传 分片暂存在临时目录中
+func (l *Local) InitiateMultipart(key string) (string, error) {
+	var id = make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	var uploadID = hex.EncodeToString(id)
+	return uploadID, os.MkdirAll(l.partDir(uploadID), 0755)
+}
+
+// UploadPart 写入分片 分片文件以编号命名
+func (l *Local) UploadPart(key, uploadID string, number int, reader io.Reader, size int64) (*Part, error) {
+	if !validUploadID(uploadID) {
+		return nil, errUploadID
+	}
+	file, err := os.Create(filepath.Join(l.partDir(uploadID), strconv.Itoa(number)))
+	if err != nil {
+		return nil, err
+	}
+	hash := md5.New()
+	written, err := io.Copy(io.MultiWriter(file, hash), io.LimitReader(reader, size))
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if err = file.Close(); err != nil {
+		return nil, err
+	}
+	return &Part{Number: number, ETag: hex.EncodeToString(hash.Sum(nil)), Size: written}, nil
+}
+
+// ListParts 列举已经上传的分片
+func (l *Local) ListParts(key, uploadID string) ([]Part, error) {
+	if !validUploadID(uploadID) {
+		return nil, errUploadID
+	}
+	infos, err := ioutil.ReadDir(l.partDir(uploadID))
+	if err != nil {
+		return nil, err
+	}
+	var parts []Part
+	for _, info := range infos {
+		number, err := strconv.Atoi(info.Name())
+		if err != nil {
+			continue
+		}
+		parts = append(parts, Part{Number: number, Size: info.Size()})
+	}
+	SortParts(parts)
+	return parts, nil
+}
+
+// CompleteMultipart 按顺序拼接分片写入文件 完成后删除临时目录
+func (l *Local) CompleteMultipart(key, uploadID string, parts []Part) error {
+	if !validUploadID(uploadID) {
+		return errUploadID
+	}
+	var names = make([]string, 0, len(parts))
+	for _, part := range parts {
+		var name = filepath.Join(l.partDir(uploadID), strconv.Itoa(part.Number))
+		//写入前确认分片都存在 避免覆盖已有文件后才发现缺少分片
+		if _, err := os.Stat(name); err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+	var reader = &partsReader{names: names}
+	defer reader.Close()
+	if err := l.Put(key, reader); err != nil {
+		return err
+	}
+	return os.RemoveAll(l.partDir(uploadID))
+}
+
+// partsReader 按顺序读取分片文件 读完一个分片并关闭后才打开下一个 分片数量不受文件描述符上限限制
+type partsReader struct {
+	names []string //尚未打开的分片文件
+	file  *os.File //正在读取的分片文件
+}
+
+// Read 读取当前分片 读完后切换到下一个分片
+func (p *partsReader) Read(b []byte) (int, error) {
+	for {
+		if p.file == nil {
+			if len(p.names) == 0 {
+				return 0, io.EOF
+			}
+			file, err := os.Open(p.names[0])
+			if err != nil {
+				return 0, err
+			}
+			p.file, p.names = file, p.names[1:]
+		}
+		n, err := p.file.Read(b)
+		if err == io.EOF {
+			_ = p.file.Close()
+			p.file = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// Close 关闭正在读取的分片文件
+func (p *partsReader) Close() error {
+	if p.file == nil {
+		return nil
+	}
+	var err = p.file.Close()
+	p.file = nil
+	return err
+}
+
+// AbortMultipart 删除分片暂存目录
+func (l *Local) AbortMultipart(key, uploadID string) error {
+	if !validUploadID(uploadID) {
+		return errUploadID
+	}
+	return os.RemoveAll(l.partDir(uploadID))
+}
+
+// Domain 访问域名 默认由Pines在 /local/ 路由下提供访问
+func (l *Local) Domain() string {
+	return l.conf.Domain
+}
+
+// LocalTargetRoute 命名的本地存储目标未设置Domain时的访问路由 规则 /local/<名称>/
+func LocalTargetRoute(name string) string {
+	return LocalRoute + name + "/"
+}
+
+// localFS 本地存储的文件访问 目录视为不存在 避免未经认证列出目录内容
+type localFS struct {
+	http.FileSystem
+}
+
+// Open 打开文件 目录返回不存在
+func (fs localFS) Open(name string) (http.File, error) {
+	file, err := fs.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		_ = file.Close()
+		return nil, os.ErrNotExist
+	}
+	return file, nil
+}
+
+// localServer 独立运行模式下本地存储文件的访问
+type localServer struct {
+	root    http.Handler            //Local.Root 下的文件 未配置本地存储时为nil
+	targets map[string]http.Handler //未设置Domain的命名本地存储目标 键为目标名称
+}
+
+// NewLocalServer 提供 /local/ 路由下的文件访问 只能访问文件 不会列出目录
+// 未设置Domain的命名本地存储目标在 /local/<名称>/ 下访问 优先于Local.Root中的同名目录
+// 未配置本地存储且没有需要访问的本地存储目标时返回nil
+func NewLocalServer(conf *config.Config) http.Handler {
+	var server = &localServer{targets: make(map[string]http.Handler)}
+	if conf.Local != (config.Local{}) {
+		var root = conf.Local.Root
+		if root == "" {
+			root = LocalDefaultRoot
+		}
+		server.root = http.StripPrefix(strings.TrimSuffix(LocalRoute, "/"), http.FileServer(localFS{http.Dir(root)}))
+	}
+	for _, target := range conf.Targets {
+		if target.Type != "Local" || target.Local.Domain != "" {
+			continue
+		}
+		var root = target.Local.Root
+		if root == "" {
+			root = LocalDefaultRoot
+		}
+		var route = LocalTargetRoute(target.Name)
+		server.targets[target.Name] = http.StripPrefix(strings.TrimSuffix(route, "/"), http.FileServer(localFS{http.Dir(root)}))
+	}
+	if server.root == nil && len(server.targets) == 0 {
+		return nil
+	}
+	return server
+}
+
+func (s *localServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var parts = strings.SplitN(strings.TrimPrefix(r.URL.Path, LocalRoute), "/", 2)
+	if len(parts) == 2 {
+		if handler, ok := s.targets[parts[0]]; ok {
+			handler.ServeHTTP(w, r)
+			return
+		}
+	}
+	if s.root == nil {
+		http.NotFound(w, r)
+		return
+	}
+	s.root.ServeHTTP(w, r)
+}
+
+// partDir 分片上传的暂存目录
+func (l *Local) partDir(uploadID string) string {
+	return filepath.Join(os.TempDir(), localMultipartDir, uploadID)
+}
+
+// validUploadID 上传ID由InitiateMultipart生成 只包含十六进制字符
+func validUploadID(uploadID string) bool {
+	if uploadID == "" {
+		return false
+	}
+	_, err := hex.DecodeString(uploadID)
+	return err == nil
+}
+
+// abs 对象在磁盘上的绝对路径 对象路径会被限制在根目录内
+func (l *Local) abs(key string) string {
+	return filepath.Join(l.conf.Root, filepath.FromSlash(path.Clean("/"+key)))
+}
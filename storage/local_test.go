@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"Pines/config"
+)
+
+func TestLocalListPages(t *testing.T) {
+	store, cleanup := newTestLocal(t)
+	defer cleanup()
+	putTestFiles(t, store, map[string]string{
+		"a.txt":     "a",
+		"b.txt":     "b",
+		"c/d.txt":   "d",
+		"e.txt":     "e",
+		"f/g/h.txt": "h",
+	})
+	var want = []string{"a.txt", "b.txt", "c/", "e.txt", "f/"}
+	for _, limit := range []int{1, 2, 4, 5, 6, 0} {
+		var names []string
+		var marker string
+		var pages int
+		for {
+			page, err := store.List("", marker, limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pages++
+			if limit > 0 && len(page.Objects) > limit {
+				t.Fatalf("limit %d: page has %d objects", limit, len(page.Objects))
+			}
+			for _, obj := range page.Objects {
+				if obj.Prefix != "" {
+					t.Errorf("limit %d: prefix of %s = %q", limit, obj.Filename, obj.Prefix)
+				}
+				names = append(names, obj.Filename)
+			}
+			if page.Next == "" {
+				break
+			}
+			marker = page.Next
+		}
+		if !reflect.DeepEqual(names, want) {
+			t.Errorf("limit %d: listed %v, want %v", limit, names, want)
+		}
+		if limit == 5 && pages != 1 {
+			t.Errorf("limit 5: listed %d pages, want 1", pages)
+		}
+	}
+}
+
+func TestLocalListMissing(t *testing.T) {
+	store, cleanup := newTestLocal(t)
+	defer cleanup()
+	page, err := store.List("missing/", "", 10)
+	if err != nil || len(page.Objects) != 0 || page.Next != "" {
+		t.Errorf("List(missing/) = %+v, %v", page, err)
+	}
+}
+
+func TestListFiles(t *testing.T) {
+	store, cleanup := newTestLocal(t)
+	defer cleanup()
+	putTestFiles(t, store, map[string]string{"a.txt": "a", "c/d.txt": "d", "f/g/h.txt": "h"})
+	if err := store.Mkdir("empty/"); err != nil {
+		t.Fatal(err)
+	}
+	files, err := ListFiles(store, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, file := range files {
+		keys = append(keys, file.Key())
+	}
+	if want := []string{"a.txt", "c/d.txt", "f/g/h.txt"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("ListFiles = %v, want %v", keys, want)
+	}
+}
+
+func TestLocalServerTargets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pines-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var conf = &config.Config{
+		Local: config.Local{Root: filepath.Join(dir, "default")},
+		Targets: []config.Target{
+			{Name: "blog", Type: "Local", Local: config.Local{Root: filepath.Join(dir, "blog")}},
+		},
+	}
+	for name, content := range map[string]string{"Local": "default", "blog": "blog"} {
+		store, err := New(name, conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = store.Put("a.txt", strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store, err := New("blog", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if domain := store.Domain(); domain != "/local/blog/" {
+		t.Errorf("Domain = %q, want /local/blog/", domain)
+	}
+	var server = NewLocalServer(conf)
+	for path, want := range map[string]string{"/local/a.txt": "default", "/local/blog/a.txt": "blog"} {
+		var w = httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK || w.Body.String() != want {
+			t.Errorf("GET %s = %d %q, want %q", path, w.Code, w.Body.String(), want)
+		}
+	}
+	//目录不会列出内容
+	for _, path := range []string{"/local/", "/local/blog/"} {
+		var w = httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", path, w.Code)
+		}
+	}
+}
+
+func TestLocalServerUnconfigured(t *testing.T) {
+	if server := NewLocalServer(&config.Config{}); server != nil {
+		t.Error("NewLocalServer without Local config should return nil")
+	}
+	//只配置了本地存储目标时 Local.Root 下的文件不可访问
+	var server = NewLocalServer(&config.Config{
+		Targets: []config.Target{{Name: "blog", Type: "Local", Local: config.Local{Root: LocalDefaultRoot}}},
+	})
+	var w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/local/a.txt", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /local/a.txt = %d, want 404", w.Code)
+	}
+}
+
+func TestNewLocalUnconfigured(t *testing.T) {
+	if _, err := NewLocal(&config.Config{}); err != errLocalNotConfigured {
+		t.Errorf("NewLocal without Local config: err = %v, want %v", err, errLocalNotConfigured)
+	}
+}
+
+func TestLocalCompleteMultipart(t *testing.T) {
+	store, cleanup := newTestLocal(t)
+	defer cleanup()
+	local := store.(*Local)
+	uploadID, err := local.InitiateMultipart("big.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.AbortMultipart("big.txt", uploadID)
+	//分片逐个打开 数量不受文件描述符上限限制
+	var parts []Part
+	var want strings.Builder
+	for i := 1; i <= 400; i++ {
+		var content = string(rune('a' + i%26))
+		part, err := local.UploadPart("big.txt", uploadID, i, strings.NewReader(content), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, *part)
+		want.WriteString(content)
+	}
+	//缺少分片时不写入文件
+	if err = local.CompleteMultipart("big.txt", uploadID, append(parts, Part{Number: 401})); err == nil {
+		t.Error("CompleteMultipart with a missing part should fail")
+	}
+	if _, err = local.Stat("big.txt"); err == nil {
+		t.Error("CompleteMultipart with a missing part wrote the file")
+	}
+	if err = local.CompleteMultipart("big.txt", uploadID, parts); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(local.abs("big.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want.String() {
+		t.Errorf("completed file has %d bytes, want %d", len(data), want.Len())
+	}
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"sort"
+)
+
+const (
+	// MaxPartSize 单个分片的大小上限 与Cos/Oss/S3一致
+	MaxPartSize = 5 << 30
+	// maxBufferedPartSize 需要将分片读入内存的驱动(又拍云/七牛云)单个分片的大小上限
+	maxBufferedPartSize = 64 << 20
+)
+
+// ErrPartTooLarge 分片超过大小上限
+var ErrPartTooLarge = errors.New("part too large")
+
+// Part 分片上传中已经上传的分片
+type Part struct {
+	Number int    `json:"number"` //分片编号 从1开始
+	ETag   string `json:"etag"`   //分片的ETag 完成上传时需要
+	Size   int64  `json:"size"`   //分片大小
+}
+
+// MultipartUploader 支持分片上传的存储驱动 用于断点续传大文件
+type MultipartUploader interface {
+	// InitiateMultipart 初始化分片上传 返回上传ID
+	InitiateMultipart(key string) (string, error)
+	// UploadPart 上传编号为number的分片 size为分片大小
+	UploadPart(key, uploadID string, number int, reader io.Reader, size int64) (*Part, error)
+	// ListParts 列举已经上传的分片 用于中断后继续上传
+	ListParts(key, uploadID string) ([]Part, error)
+	// CompleteMultipart 按分片编号顺序合并分片 完成上传
+	CompleteMultipart(key, uploadID string, parts []Part) error
+	// AbortMultipart 取消分片上传并清理已上传的分片
+	AbortMultipart(key, uploadID string) error
+}
+
+// Multipart 获取存储驱动的分片上传接口 驱动未实现MultipartUploader时返回ErrNotSupported
+func Multipart(store Storage) (MultipartUploader, error) {
+	m, ok := store.(MultipartUploader)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return m, nil
+}
+
+// SortParts 按分片编号排序 完成分片上传时分片需要按编号升序排列
+func SortParts(parts []Part) {
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].Number < parts[j].Number
+	})
+}
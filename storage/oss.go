@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+
+	"Pines/config"
+)
+
+// Oss 阿里云Oss服务
+type Oss struct {
+	conf   config.Oss
+	bucket *oss.Bucket
+}
+
+func init() {
+	Register("Oss", NewOss)
+}
+
+// NewOss 初始化阿里云Oss客户端
+func NewOss(conf *config.Config) (Storage, error) {
+	client, err := oss.New(conf.Oss.Endpoint, conf.Oss.Ak, conf.Oss.Sk)
+	if err != nil {
+		return nil, err
+	}
+	// 获取存储空间。
+	bucket, err := client.Bucket(conf.Oss.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	return &Oss{conf: conf.Oss, bucket: bucket}, nil
+}
+
+// List 分页列举当前目录下的文件 游标为Oss的NextMarker
+func (o *Oss) List(path, marker string, limit int) (*Page, error) {
+	if marker == "" {
+		marker = path
+	}
+	//设置筛选器
+	var options = []oss.Option{oss.Marker(marker), oss.Prefix(path), oss.Delimiter("/")}
+	if limit > 0 {
+		options = append(options, oss.MaxKeys(limit))
+	}
+	lsRes, err := o.bucket.ListObjects(options...)
+	if err != nil {
+		return nil, err
+	}
+	var page = new(Page)
+	for _, dirname := range lsRes.CommonPrefixes {
+		page.Objects = append(page.Objects, ListObject{
+			Filename:   strings.Replace(dirname, path, "", 1),
+			CreateTime: time.Time{},
+			IsDir:      true,
+			Prefix:     path,
+		})
+	}
+	for _, obj := range lsRes.Objects {
+		page.Objects = append(page.Objects, ListObject{
+			Filename:   strings.Replace(obj.Key, path, "", 1),
+			CreateTime: obj.LastModified,
+			IsDir:      false,
+			Prefix:     path,
+			Size:       obj.Size,
+			ETag:       obj.ETag,
+		})
+	}
+	if lsRes.IsTruncated {
+		page.Next = lsRes.NextMarker
+	}
+	return page, nil
+}
+
+// Delete 删除对象
+func (o *Oss) Delete(path string) error {
+	return o.bucket.DeleteObject(path)
+}
+
+// Put 上传对象
+func (o *Oss) Put(key string, reader io.Reader) error {
+	return o.bucket.PutObject(key, reader)
+}
+
+// DeleteMulti 批量删除对象
+func (o *Oss) DeleteMulti(keys []string) *Result {
+	var result = new(Result)
+	res, err := o.bucket.DeleteObjects(keys)
+	if err != nil {
+		for _, key := range keys {
+			result.fail(key, err)
+		}
+		return result
+	}
+	var deleted = make(map[string]bool)
+	for _, key := range res.DeletedObjects {
+		deleted[key] = true
+	}
+	for _, key := range keys {
+		if deleted[key] {
+			result.ok(key)
+		} else {
+			result.fail(key, errors.New("object not deleted"))
+		}
+	}
+	return result
+}
+
+// Mkdir 创建目录
+func (o *Oss) Mkdir(path string) error {
+	return o.bucket.PutObject(path, strings.NewReader(""))
+}
+
+// Get 读取对象内容
+func (o *Oss) Get(path string) (io.ReadCloser, error) {
+	return o.bucket.GetObject(path)
+}
+
+// GetRange 从offset处开始读取对象内容
+func (o *Oss) GetRange(path string, offset int64) (io.ReadCloser, error) {
+	return o.bucket.GetObject(path, oss.NormalizedRange(strconv.FormatInt(offset, 10)+"-"))
+}
+
+// Move 移动对象 使用服务端复制后删除源对象
+func (o *Oss) Move(src, dst string) error {
+	if err := o.Copy(src, dst); err != nil {
+		return err
+	}
+	return o.Delete(src)
+}
+
+// Copy 服务端复制对象
+func (o *Oss) Copy(src, dst string) error {
+	_, err := o.bucket.CopyObject(src, dst)
+	return err
+}
+
+// Stat 获取对象元信息
+func (o *Oss) Stat(path string) (*ObjectInfo, error) {
+	header, err := o.bucket.GetObjectDetailedMeta(path)
+	if err != nil {
+		return nil, err
+	}
+	info := &ObjectInfo{
+		Key:   path,
+		IsDir: strings.HasSuffix(path, "/"),
+	}
+	parseHeader(info, header, "oss")
+	return info, nil
+}
+
+// Sign 生成签名的下载地址
+func (o *Oss) Sign(path string, expire time.Duration) (string, error) {
+	return o.bucket.SignURL(path, oss.HTTPGet, int64(expire/time.Second))
+}
+
+// PresignUpload 签发PostObject表单上传的Policy
+func (o *Oss) PresignUpload(key string, expire time.Duration) (*UploadPolicy, error) {
+	var deadline = time.Now().Add(expire)
+	policy, err := json.Marshal(map[string]interface{}{
+		"expiration": deadline.UTC().Format("2006-01-02T15:04:05.000Z"),
+		"conditions": []interface{}{
+			map[string]string{"bucket": o.conf.Bucket},
+			[]string{"eq", "$key", key},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var encoded = base64.StdEncoding.EncodeToString(policy)
+	mac := hmac.New(sha1.New, []byte(o.conf.Sk))
+	_, _ = mac.Write([]byte(encoded))
+	//Endpoint可能携带协议
+	var host = o.conf.Endpoint
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	return &UploadPolicy{
+		Method: http.MethodPost,
+		URL:    "https://" + o.conf.Bucket + "." + host,
+		Key:    key,
+		Fields: map[string]string{
+			"key":                   key,
+			"OSSAccessKeyId":        o.conf.Ak,
+			"policy":                encoded,
+			"Signature":             base64.StdEncoding.EncodeToString(mac.Sum(nil)),
+			"success_action_status": "200",
+		},
+		FileField: "file",
+		Expire:    deadline,
+	}, nil
+}
+
+// InitiateMultipart 初始化分片上传
+func (o *Oss) InitiateMultipart(key string) (string, error) {
+	imur, err := o.bucket.InitiateMultipartUpload(key)
+	if err != nil {
+		return "", err
+	}
+	return imur.UploadID, nil
+}
+
+// UploadPart 上传分片
+func (o *Oss) UploadPart(key, uploadID string, number int, reader io.Reader, size int64) (*Part, error) {
+	part, err := o.bucket.UploadPart(o.imur(key, uploadID), reader, size, number)
+	if err != nil {
+		return nil, err
+	}
+	return &Part{Number: part.PartNumber, ETag: part.ETag, Size: size}, nil
+}
+
+// ListParts 列举已经上传的分片
+func (o *Oss) ListParts(key, uploadID string) ([]Part, error) {
+	var parts []Part
+	var marker int
+	for {
+		res, err := o.bucket.ListUploadedParts(o.imur(key, uploadID), oss.PartNumberMarker(marker))
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range res.UploadedParts {
+			parts = append(parts, Part{Number: part.PartNumber, ETag: part.ETag, Size: int64(part.Size)})
+		}
+		if !res.IsTruncated {
+			return parts, nil
+		}
+		if marker, err = strconv.Atoi(res.NextPartNumberMarker); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// CompleteMultipart 完成分片上传
+func (o *Oss) CompleteMultipart(key, uploadID string, parts []Part) error {
+	var uploaded = make([]oss.UploadPart, 0, len(parts))
+	for _, part := range parts {
+		uploaded = append(uploaded, oss.UploadPart{PartNumber: part.Number, ETag: part.ETag})
+	}
+	_, err := o.bucket.CompleteMultipartUpload(o.imur(key, uploadID), uploaded)
+	return err
+}
+
+// AbortMultipart 取消分片上传
+func (o *Oss) AbortMultipart(key, uploadID string) error {
+	return o.bucket.AbortMultipartUpload(o.imur(key, uploadID))
+}
+
+// imur 分片上传的标识
+func (o *Oss) imur(key, uploadID string) oss.InitiateMultipartUploadResult {
+	return oss.InitiateMultipartUploadResult{Bucket: o.conf.Bucket, Key: key, UploadID: uploadID}
+}
+
+// Domain 访问域名
+func (o *Oss) Domain() string {
+	return o.conf.Domain
+}
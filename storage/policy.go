@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotSupported 存储驱动不支持该操作
+var ErrNotSupported = errors.New("operation not supported by this storage")
+
+// UploadPolicy 浏览器直传使用的上传凭证
+// Method为POST时以表单上传 Fields需要按顺序写在文件字段之前
+// Method为PUT时直接将文件内容作为请求体上传到URL
+type UploadPolicy struct {
+	Method    string            `json:"method"`               //上传请求方式 POST/PUT
+	URL       string            `json:"url"`                  //上传地址
+	Key       string            `json:"key"`                  //对象的绝对路径
+	Fields    map[string]string `json:"fields,omitempty"`     //表单上传需要携带的字段
+	FileField string            `json:"file_field,omitempty"` //表单上传中文件的字段名
+	Headers   map[string]string `json:"headers,omitempty"`    //PUT上传需要携带的请求头
+	Expire    time.Time         `json:"expire"`               //凭证的过期时间
+}
+
+// Presigner 支持签发浏览器直传凭证的存储驱动
+type Presigner interface {
+	// PresignUpload 签发只能上传到key且有效期为expire的上传凭证
+	PresignUpload(key string, expire time.Duration) (*UploadPolicy, error)
+}
+
+// PresignUpload 签发浏览器直传凭证 驱动未实现Presigner时返回ErrNotSupported
+func PresignUpload(store Storage, key string, expire time.Duration) (*UploadPolicy, error) {
+	p, ok := store.(Presigner)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return p.PresignUpload(key, expire)
+}
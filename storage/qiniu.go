@@ -0,0 +1,448 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"Pines/config"
+)
+
+const (
+	// qiniuDefaultRegion 未设置存储区域时使用华东区域
+	qiniuDefaultRegion = "z0"
+	// qiniuTokenExpire 上传凭证的有效期
+	qiniuTokenExpire = time.Hour
+)
+
+// Qiniu 七牛云Kodo服务
+type Qiniu struct {
+	conf config.Qiniu
+}
+
+// qiniuEntry 七牛云接口返回的对象信息
+type qiniuEntry struct {
+	Key      string `json:"key"`
+	Hash     string `json:"hash"`
+	Size     int64  `json:"fsize"`
+	MimeType string `json:"mimeType"`
+	PutTime  int64  `json:"putTime"` //上传时间 单位为100纳秒
+	Type     int    `json:"type"`    //存储类型
+
+	Meta map[string]string `json:"x-qn-meta"` //用户自定义元信息
+}
+
+// qiniuStorageClass 七牛云存储类型
+var qiniuStorageClass = map[int]string{
+	0: "STANDARD",
+	1: "LINE",
+	2: "ARCHIVE",
+	3: "DEEP_ARCHIVE",
+}
+
+func init() {
+	Register("Qiniu", NewQiniu)
+}
+
+// NewQiniu 初始化七牛云客户端
+func NewQiniu(conf *config.Config) (Storage, error) {
+	var q = conf.Qiniu
+	if q.Region == "" {
+		q.Region = qiniuDefaultRegion
+	}
+	return &Qiniu{conf: q}, nil
+}
+
+// List 分页列举当前目录下的文件 游标为七牛云的marker
+func (q *Qiniu) List(prefix, marker string, limit int) (*Page, error) {
+	var query = url.Values{}
+	query.Set("bucket", q.conf.Bucket)
+	query.Set("prefix", prefix)
+	query.Set("delimiter", "/")
+	if marker != "" {
+		query.Set("marker", marker)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var res struct {
+		Marker         string       `json:"marker"`
+		CommonPrefixes []string     `json:"commonPrefixes"`
+		Items          []qiniuEntry `json:"items"`
+	}
+	if err := q.manage(http.MethodPost, q.host("rsf"), "/list", query, nil, &res); err != nil {
+		return nil, err
+	}
+	var page = new(Page)
+	for _, dirname := range res.CommonPrefixes {
+		page.Objects = append(page.Objects, ListObject{
+			Filename:   strings.Replace(dirname, prefix, "", 1),
+			CreateTime: time.Time{},
+			IsDir:      true,
+			Prefix:     prefix,
+		})
+	}
+	for _, obj := range res.Items {
+		//跳过mkdir创建的目录占位对象
+		if obj.Key == prefix {
+			continue
+		}
+		page.Objects = append(page.Objects, ListObject{
+			Filename:   strings.Replace(obj.Key, prefix, "", 1),
+			CreateTime: time.Unix(0, obj.PutTime*100),
+			IsDir:      false,
+			Prefix:     prefix,
+			Size:       obj.Size,
+			ETag:       obj.Hash,
+		})
+	}
+	page.Next = res.Marker
+	return page, nil
+}
+
+// Delete 删除对象
+func (q *Qiniu) Delete(path string) error {
+	return q.manage(http.MethodPost, q.host("rs"), "/delete/"+q.entry(path), nil, nil, nil)
+}
+
+// DeleteMulti 批量删除对象
+func (q *Qiniu) DeleteMulti(keys []string) *Result {
+	var result = new(Result)
+	var form = url.Values{}
+	for _, key := range keys {
+		form.Add("op", "/delete/"+q.entry(key))
+	}
+	var res []struct {
+		Code int `json:"code"`
+		Data struct {
+			Error string `json:"error"`
+		} `json:"data"`
+	}
+	if err := q.manage(http.MethodPost, q.host("rs"), "/batch", nil, form, &res); err != nil {
+		for _, key := range keys {
+			result.fail(key, err)
+		}
+		return result
+	}
+	//返回结果与请求的操作顺序一致
+	for i, key := range keys {
+		if i < len(res) && res[i].Code == http.StatusOK {
+			result.ok(key)
+			continue
+		}
+		var msg = "object not deleted"
+		if i < len(res) && res[i].Data.Error != "" {
+			msg = res[i].Data.Error
+		}
+		result.fail(key, errors.New(msg))
+	}
+	return result
+}
+
+// Put 通过表单上传对象 上传凭证由AccessKey/SecretKey签发
+func (q *Qiniu) Put(key string, reader io.Reader) error {
+	pr, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+	go func() {
+		var err error
+		defer func() {
+			_ = pw.CloseWithError(err)
+		}()
+		if err = form.WriteField("token", q.uploadToken(key, qiniuTokenExpire)); err != nil {
+			return
+		}
+		if err = form.WriteField("key", key); err != nil {
+			return
+		}
+		part, err := form.CreateFormFile("file", key)
+		if err != nil {
+			return
+		}
+		if _, err = io.Copy(part, reader); err != nil {
+			return
+		}
+		err = form.Close()
+	}()
+	req, err := http.NewRequest(http.MethodPost, q.upHost(), pr)
+	if err != nil {
+		//结束写入表单的协程
+		_ = pr.CloseWithError(err)
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return q.do(req, nil)
+}
+
+// Mkdir 创建目录 七牛云没有目录的概念 使用以 / 结尾的空对象占位
+func (q *Qiniu) Mkdir(path string) error {
+	return q.Put(path, strings.NewReader(""))
+}
+
+// Get 通过访问域名下载对象 使用私有空间的下载凭证 对公开空间同样有效
+func (q *Qiniu) Get(path string) (io.ReadCloser, error) {
+	return q.GetRange(path, 0)
+}
+
+// GetRange 从offset处开始读取对象内容
+func (q *Qiniu) GetRange(path string, offset int64) (io.ReadCloser, error) {
+	link, _ := q.Sign(path, qiniuTokenExpire)
+	req, err := http.NewRequest(http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("GET %d %s", resp.StatusCode, path)
+	}
+	return resp.Body, nil
+}
+
+// Move 移动对象 目标已存在时强制覆盖
+func (q *Qiniu) Move(src, dst string) error {
+	return q.manage(http.MethodPost, q.host("rs"), "/move/"+q.entry(src)+"/"+q.entry(dst)+"/force/true", nil, nil, nil)
+}
+
+// Copy 服务端复制对象 目标已存在时强制覆盖
+func (q *Qiniu) Copy(src, dst string) error {
+	return q.manage(http.MethodPost, q.host("rs"), "/copy/"+q.entry(src)+"/"+q.entry(dst)+"/force/true", nil, nil, nil)
+}
+
+// Stat 获取对象元信息
+func (q *Qiniu) Stat(path string) (*ObjectInfo, error) {
+	var obj qiniuEntry
+	if err := q.manage(http.MethodGet, q.host("rs"), "/stat/"+q.entry(path), nil, nil, &obj); err != nil {
+		return nil, err
+	}
+	return &ObjectInfo{
+		Key:          path,
+		IsDir:        strings.HasSuffix(path, "/"),
+		Size:         obj.Size,
+		LastModified: time.Unix(0, obj.PutTime*100),
+		ContentType:  obj.MimeType,
+		ETag:         obj.Hash,
+		StorageClass: qiniuStorageClass[obj.Type],
+		Metadata:     obj.Meta,
+	}, nil
+}
+
+// Sign 生成私有空间的下载地址
+func (q *Qiniu) Sign(path string, expire time.Duration) (string, error) {
+	var link = q.conf.Domain + (&url.URL{Path: path}).EscapedPath()
+	link += "?e=" + strconv.FormatInt(time.Now().Add(expire).Unix(), 10)
+	return link + "&token=" + q.sign([]byte(link)), nil
+}
+
+// PresignUpload 签发表单上传使用的上传凭证
+func (q *Qiniu) PresignUpload(key string, expire time.Duration) (*UploadPolicy, error) {
+	return &UploadPolicy{
+		Method: http.MethodPost,
+		URL:    q.upHost(),
+		Key:    key,
+		Fields: map[string]string{
+			"token": q.uploadToken(key, expire),
+			"key":   key,
+		},
+		FileField: "file",
+		Expire:    time.Now().Add(expire),
+	}, nil
+}
+
+// InitiateMultipart 初始化分片上传 使用分片上传v2接口
+func (q *Qiniu) InitiateMultipart(key string) (string, error) {
+	var res struct {
+		UploadID string `json:"uploadId"`
+	}
+	if err := q.multipart(http.MethodPost, key, "", nil, &res); err != nil {
+		return "", err
+	}
+	return res.UploadID, nil
+}
+
+// UploadPart 上传分片
+func (q *Qiniu) UploadPart(key, uploadID string, number int, reader io.Reader, size int64) (*Part, error) {
+	var res struct {
+		ETag string `json:"etag"`
+	}
+	//分片接口需要Content-Length 分片内容读入内存后再上传
+	if size > maxBufferedPartSize {
+		return nil, ErrPartTooLarge
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(reader, size))
+	if err != nil {
+		return nil, err
+	}
+	if err = q.multipart(http.MethodPut, key, uploadID+"/"+strconv.Itoa(number), bytes.NewReader(data), &res); err != nil {
+		return nil, err
+	}
+	return &Part{Number: number, ETag: res.ETag, Size: int64(len(data))}, nil
+}
+
+// ListParts 列举已经上传的分片
+func (q *Qiniu) ListParts(key, uploadID string) ([]Part, error) {
+	var parts []Part
+	var marker int
+	for {
+		var res struct {
+			Marker int `json:"partNumberMarker"`
+			Parts  []struct {
+				Number int    `json:"partNumber"`
+				ETag   string `json:"etag"`
+				Size   int64  `json:"size"`
+			} `json:"parts"`
+		}
+		if err := q.multipart(http.MethodGet, key, uploadID+"?part-number-marker="+strconv.Itoa(marker), nil, &res); err != nil {
+			return nil, err
+		}
+		for _, part := range res.Parts {
+			parts = append(parts, Part{Number: part.Number, ETag: part.ETag, Size: part.Size})
+		}
+		//列举完毕时返回的游标为0
+		if res.Marker == 0 || len(res.Parts) == 0 {
+			return parts, nil
+		}
+		marker = res.Marker
+	}
+}
+
+// CompleteMultipart 完成分片上传
+func (q *Qiniu) CompleteMultipart(key, uploadID string, parts []Part) error {
+	type part struct {
+		Number int    `json:"partNumber"`
+		ETag   string `json:"etag"`
+	}
+	var completed = make([]part, 0, len(parts))
+	for _, p := range parts {
+		completed = append(completed, part{Number: p.Number, ETag: p.ETag})
+	}
+	body, err := json.Marshal(map[string]interface{}{"parts": completed})
+	if err != nil {
+		return err
+	}
+	return q.multipart(http.MethodPost, key, uploadID, bytes.NewReader(body), nil)
+}
+
+// AbortMultipart 取消分片上传
+func (q *Qiniu) AbortMultipart(key, uploadID string) error {
+	return q.multipart(http.MethodDelete, key, uploadID, nil, nil)
+}
+
+// Domain 访问域名
+func (q *Qiniu) Domain() string {
+	return q.conf.Domain
+}
+
+// host 管理接口的地址 service为 rs/rsf
+func (q *Qiniu) host(service string) string {
+	return "https://" + service + "-" + q.conf.Region + ".qiniuapi.com"
+}
+
+// upHost 上传接口的地址
+func (q *Qiniu) upHost() string {
+	return "https://up-" + q.conf.Region + ".qiniup.com"
+}
+
+// entry 编码后的 bucket:key
+func (q *Qiniu) entry(key string) string {
+	return base64.URLEncoding.EncodeToString([]byte(q.conf.Bucket + ":" + key))
+}
+
+// sign 使用SecretKey对data签名 返回 AccessKey:Sign
+func (q *Qiniu) sign(data []byte) string {
+	mac := hmac.New(sha1.New, []byte(q.conf.SecretKey))
+	_, _ = mac.Write(data)
+	return q.conf.AccessKey + ":" + base64.URLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// uploadToken 签发只能上传到key且有效期为expire的上传凭证
+func (q *Qiniu) uploadToken(key string, expire time.Duration) string {
+	policy, _ := json.Marshal(map[string]interface{}{
+		"scope":    q.conf.Bucket + ":" + key,
+		"deadline": time.Now().Add(expire).Unix(),
+	})
+	encoded := base64.URLEncoding.EncodeToString(policy)
+	return q.sign([]byte(encoded)) + ":" + encoded
+}
+
+// multipart 调用分片上传v2接口 uri为上传ID及之后的部分 使用上传凭证认证
+func (q *Qiniu) multipart(method, key, uri string, body io.Reader, out interface{}) error {
+	var link = q.upHost() + "/buckets/" + q.conf.Bucket + "/objects/" + base64.URLEncoding.EncodeToString([]byte(key)) + "/uploads"
+	if uri != "" {
+		link += "/" + uri
+	}
+	req, err := http.NewRequest(method, link, body)
+	if err != nil {
+		return err
+	}
+	if method == http.MethodPut {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	} else if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "UpToken "+q.uploadToken(key, qiniuTokenExpire))
+	return q.do(req, out)
+}
+
+// manage 调用管理接口 使用QBox方式签名
+func (q *Qiniu) manage(method, host, path string, query, form url.Values, out interface{}) error {
+	var uri = path
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+	var body = form.Encode()
+	req, err := http.NewRequest(method, host+uri, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	var data = uri + "\n"
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		data += body
+	}
+	req.Header.Set("Authorization", "QBox "+q.sign([]byte(data)))
+	return q.do(req, out)
+}
+
+// do 发送请求并解析返回的JSON
+func (q *Qiniu) do(req *http.Request, out interface{}) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		var res struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &res) == nil && res.Error != "" {
+			return errors.New(res.Error)
+		}
+		return fmt.Errorf("%s %d %s", req.Method, resp.StatusCode, string(body))
+	}
+	if out == nil || len(body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// RangeGetter 支持从指定位置读取对象的存储驱动 用于HTTP Range请求
+type RangeGetter interface {
+	// GetRange 从offset处开始读取对象内容 调用方负责关闭
+	GetRange(path string, offset int64) (io.ReadCloser, error)
+}
+
+// errSeek 无效的读取位置
+var errSeek = errors.New("seek: invalid offset")
+
+// ObjectReader 可以随机读取的对象内容 在首次Read时才向存储服务发起请求
+// Seek后会重新从新的位置读取 可以配合 http.ServeContent 处理Range请求
+type ObjectReader struct {
+	store  Storage
+	path   string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+// NewObjectReader 创建大小为size的对象的随机读取器
+func NewObjectReader(store Storage, path string, size int64) *ObjectReader {
+	return &ObjectReader{store: store, path: path, size: size}
+}
+
+// Read 读取对象内容
+func (o *ObjectReader) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+	if o.body == nil {
+		body, err := o.open()
+		if err != nil {
+			return 0, err
+		}
+		o.body = body
+	}
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+// Seek 移动读取位置 位置改变时关闭当前的连接
+func (o *ObjectReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += o.offset
+	case io.SeekEnd:
+		offset += o.size
+	}
+	if offset < 0 {
+		return 0, errSeek
+	}
+	if offset != o.offset {
+		_ = o.Close()
+		o.offset = offset
+	}
+	return offset, nil
+}
+
+// Close 关闭当前的连接
+func (o *ObjectReader) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	err := o.body.Close()
+	o.body = nil
+	return err
+}
+
+// open 从当前位置开始读取 驱动不支持Range时读取完整内容并跳过offset之前的部分
+func (o *ObjectReader) open() (io.ReadCloser, error) {
+	if getter, ok := o.store.(RangeGetter); ok {
+		return getter.GetRange(o.path, o.offset)
+	}
+	body, err := o.store.Get(o.path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.CopyN(ioutil.Discard, body, o.offset); err != nil {
+		_ = body.Close()
+		return nil, err
+	}
+	return body, nil
+}
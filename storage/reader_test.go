@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// noRange 隐藏驱动的GetRange 用于测试不支持Range的驱动
+type noRange struct {
+	Storage
+}
+
+func TestObjectReader(t *testing.T) {
+	local, cleanup := newTestLocal(t)
+	defer cleanup()
+	const content = "0123456789"
+	putTestFiles(t, local, map[string]string{"a.txt": content})
+	var tests = []struct {
+		offset int64
+		whence int
+		pos    int64
+		want   string
+	}{
+		{0, io.SeekStart, 0, content},
+		{3, io.SeekStart, 3, "3456789"},
+		{-4, io.SeekEnd, 6, "6789"},
+		{0, io.SeekEnd, 10, ""},
+		{12, io.SeekStart, 12, ""},
+	}
+	for _, store := range []Storage{local, noRange{local}} {
+		for _, test := range tests {
+			reader := NewObjectReader(store, "a.txt", int64(len(content)))
+			pos, err := reader.Seek(test.offset, test.whence)
+			if err != nil || pos != test.pos {
+				t.Fatalf("Seek(%d, %d) = %d, %v, want %d", test.offset, test.whence, pos, err, test.pos)
+			}
+			data, err := ioutil.ReadAll(reader)
+			if err != nil || string(data) != test.want {
+				t.Errorf("read after Seek(%d, %d) = %q, %v, want %q", test.offset, test.whence, data, err, test.want)
+			}
+			_ = reader.Close()
+		}
+	}
+}
+
+func TestObjectReaderSeekAfterRead(t *testing.T) {
+	store, cleanup := newTestLocal(t)
+	defer cleanup()
+	putTestFiles(t, store, map[string]string{"a.txt": "0123456789"})
+	reader := NewObjectReader(store, "a.txt", 10)
+	defer reader.Close()
+	var head = make([]byte, 4)
+	if _, err := io.ReadFull(reader, head); err != nil || string(head) != "0123" {
+		t.Fatalf("ReadFull = %q, %v", head, err)
+	}
+	if pos, _ := reader.Seek(0, io.SeekCurrent); pos != 4 {
+		t.Fatalf("Seek(0, SeekCurrent) = %d, want 4", pos)
+	}
+	if _, err := reader.Seek(-2, io.SeekCurrent); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadAll(reader); string(data) != "23456789" {
+		t.Errorf("read after seeking back = %q", data)
+	}
+	if _, err := reader.Seek(-11, io.SeekEnd); err == nil {
+		t.Error("Seek before start succeeded")
+	}
+}
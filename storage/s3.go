@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v6"
+	"github.com/minio/minio-go/v6/pkg/credentials"
+
+	"Pines/config"
+)
+
+// s3StreamPartSize 未知大小的对象上传时的分片大小
+const s3StreamPartSize = 16 << 20
+
+// S3 Amazon S3及兼容S3协议的存储服务 如 MinIO/R2/Wasabi
+type S3 struct {
+	conf config.S3
+	core minio.Core
+}
+
+func init() {
+	Register("S3", NewS3)
+}
+
+// NewS3 初始化S3客户端
+func NewS3(conf *config.Config) (Storage, error) {
+	var lookup = minio.BucketLookupAuto
+	if conf.S3.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	client, err := minio.NewWithOptions(conf.S3.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(conf.S3.AccessKey, conf.S3.SecretKey, ""),
+		Secure:       conf.S3.UseSSL,
+		Region:       conf.S3.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &S3{conf: conf.S3, core: minio.Core{Client: client}}, nil
+}
+
+// List 分页列举当前目录下的文件 游标为S3的ContinuationToken
+func (s *S3) List(prefix, marker string, limit int) (*Page, error) {
+	var startAfter string
+	if marker == "" {
+		startAfter = prefix
+	}
+	res, err := s.core.ListObjectsV2(s.conf.Bucket, prefix, marker, false, "/", limit, startAfter)
+	if err != nil {
+		return nil, err
+	}
+	var page = new(Page)
+	for _, dir := range res.CommonPrefixes {
+		page.Objects = append(page.Objects, ListObject{
+			Filename:   strings.Replace(dir.Prefix, prefix, "", 1),
+			CreateTime: time.Time{},
+			IsDir:      true,
+			Prefix:     prefix,
+		})
+	}
+	for _, obj := range res.Contents {
+		page.Objects = append(page.Objects, ListObject{
+			Filename:   strings.Replace(obj.Key, prefix, "", 1),
+			CreateTime: obj.LastModified,
+			IsDir:      false,
+			Prefix:     prefix,
+			Size:       obj.Size,
+			ETag:       obj.ETag,
+		})
+	}
+	if res.IsTruncated {
+		page.Next = res.NextContinuationToken
+	}
+	return page, nil
+}
+
+// Delete 删除对象
+func (s *S3) Delete(path string) error {
+	return s.core.RemoveObject(s.conf.Bucket, path)
+}
+
+// DeleteMulti 批量删除对象
+func (s *S3) DeleteMulti(keys []string) *Result {
+	var result = new(Result)
+	objectsCh := make(chan string, len(keys))
+	for _, key := range keys {
+		objectsCh <- key
+	}
+	close(objectsCh)
+	var failed = make(map[string]bool)
+	for e := range s.core.RemoveObjects(s.conf.Bucket, objectsCh) {
+		failed[e.ObjectName] = true
+		result.fail(e.ObjectName, e.Err)
+	}
+	for _, key := range keys {
+		if !failed[key] {
+			result.ok(key)
+		}
+	}
+	return result
+}
+
+// Put 上传对象 已知大小的小文件使用单次PUT上传 ETag为内容的MD5
+// 未知大小的对象以s3StreamPartSize大小的分片上传 避免SDK按5TB估算分片大小占用大量内存
+func (s *S3) Put(key string, reader io.Reader) error {
+	var opts minio.PutObjectOptions
+	var size = readerSize(reader)
+	if size < 0 {
+		opts.PartSize = s3StreamPartSize
+	}
+	_, err := s.core.Client.PutObject(s.conf.Bucket, key, reader, size, opts)
+	return err
+}
+
+// Mkdir 创建目录
+func (s *S3) Mkdir(path string) error {
+	_, err := s.core.Client.PutObject(s.conf.Bucket, path, strings.NewReader(""), 0, minio.PutObjectOptions{})
+	return err
+}
+
+// Get 读取对象内容
+func (s *S3) Get(path string) (io.ReadCloser, error) {
+	reader, _, _, err := s.core.GetObject(s.conf.Bucket, path, minio.GetObjectOptions{})
+	return reader, err
+}
+
+// GetRange 从offset处开始读取对象内容
+func (s *S3) GetRange(path string, offset int64) (io.ReadCloser, error) {
+	var opts = minio.GetObjectOptions{}
+	if offset > 0 {
+		if err := opts.SetRange(offset, 0); err != nil {
+			return nil, err
+		}
+	}
+	reader, _, _, err := s.core.GetObject(s.conf.Bucket, path, opts)
+	return reader, err
+}
+
+// Move 移动对象 使用服务端复制后删除源对象
+func (s *S3) Move(src, dst string) error {
+	if err := s.Copy(src, dst); err != nil {
+		return err
+	}
+	return s.Delete(src)
+}
+
+// Copy 服务端复制对象
+func (s *S3) Copy(src, dst string) error {
+	_, err := s.core.CopyObject(s.conf.Bucket, src, s.conf.Bucket, dst, nil)
+	return err
+}
+
+// Stat 获取对象元信息
+func (s *S3) Stat(path string) (*ObjectInfo, error) {
+	obj, err := s.core.StatObject(s.conf.Bucket, path, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	info := &ObjectInfo{
+		Key:   path,
+		IsDir: strings.HasSuffix(path, "/"),
+	}
+	parseHeader(info, obj.Metadata, "amz")
+	info.Size = obj.Size
+	info.LastModified = obj.LastModified
+	info.ETag = obj.ETag
+	if info.StorageClass == "" {
+		info.StorageClass = obj.StorageClass
+	}
+	return info, nil
+}
+
+// Sign 生成预签名的下载地址
+func (s *S3) Sign(path string, expire time.Duration) (string, error) {
+	u, err := s.core.PresignedGetObject(s.conf.Bucket, path, expire, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// PresignUpload 生成预签名的PUT上传地址
+func (s *S3) PresignUpload(key string, expire time.Duration) (*UploadPolicy, error) {
+	u, err := s.core.PresignedPutObject(s.conf.Bucket, key, expire)
+	if err != nil {
+		return nil, err
+	}
+	return &UploadPolicy{
+		Method: http.MethodPut,
+		URL:    u.String(),
+		Key:    key,
+		Expire: time.Now().Add(expire),
+	}, nil
+}
+
+// InitiateMultipart 初始化分片上传
+func (s *S3) InitiateMultipart(key string) (string, error) {
+	return s.core.NewMultipartUpload(s.conf.Bucket, key, minio.PutObjectOptions{})
+}
+
+// UploadPart 上传分片
+func (s *S3) UploadPart(key, uploadID string, number int, reader io.Reader, size int64) (*Part, error) {
+	part, err := s.core.PutObjectPart(s.conf.Bucket, key, uploadID, number, reader, size, "", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Part{Number: part.PartNumber, ETag: part.ETag, Size: part.Size}, nil
+}
+
+// ListParts 列举已经上传的分片
+func (s *S3) ListParts(key, uploadID string) ([]Part, error) {
+	var parts []Part
+	var marker int
+	for {
+		res, err := s.core.ListObjectParts(s.conf.Bucket, key, uploadID, marker, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range res.ObjectParts {
+			parts = append(parts, Part{Number: part.PartNumber, ETag: part.ETag, Size: part.Size})
+		}
+		if !res.IsTruncated {
+			return parts, nil
+		}
+		marker = res.NextPartNumberMarker
+	}
+}
+
+// CompleteMultipart 完成分片上传
+func (s *S3) CompleteMultipart(key, uploadID string, parts []Part) error {
+	var completed = make([]minio.CompletePart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, minio.CompletePart{PartNumber: part.Number, ETag: part.ETag})
+	}
+	_, err := s.core.CompleteMultipartUpload(s.conf.Bucket, key, uploadID, completed)
+	return err
+}
+
+// AbortMultipart 取消分片上传
+func (s *S3) AbortMultipart(key, uploadID string) error {
+	return s.core.AbortMultipartUpload(s.conf.Bucket, key, uploadID)
+}
+
+// Domain 访问域名 未设置自定义域名时根据Endpoint生成
+func (s *S3) Domain() string {
+	if s.conf.Domain != "" {
+		return s.conf.Domain
+	}
+	var scheme = "http://"
+	if s.conf.UseSSL {
+		scheme = "https://"
+	}
+	if s.conf.PathStyle {
+		return scheme + s.conf.Endpoint + "/" + s.conf.Bucket + "/"
+	}
+	return scheme + s.conf.Bucket + "." + s.conf.Endpoint + "/"
+}
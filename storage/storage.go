@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"Pines/config"
+)
+
+// ListObject 对象列表
+type ListObject struct {
+	Filename   string      `json:"filename"`
+	Prefix     string      `json:"prefix"`
+	IsDir      bool        `json:"is_dir"`
+	Size       interface{} `json:"size"`
+	CreateTime interface{} `json:"create_time"`
+	ETag       string      `json:"etag,omitempty"` //列举结果中的ETag 又拍云与本地存储为空
+}
+
+// ObjectInfo 对象元信息
+type ObjectInfo struct {
+	Key          string            `json:"key"`           //对象的绝对路径
+	IsDir        bool              `json:"is_dir"`        //是否为目录
+	Size         int64             `json:"size"`          //对象大小
+	LastModified time.Time         `json:"last_modified"` //最后修改时间
+	ContentType  string            `json:"content_type"`  //对象的MIME类型
+	ETag         string            `json:"etag"`          //对象的ETag 多数情况下为内容的MD5
+	StorageClass string            `json:"storage_class"` //存储类型
+	Headers      map[string]string `json:"headers"`       //对象的自定义响应头 如 Cache-Control
+	Metadata     map[string]string `json:"metadata"`      //用户自定义元信息
+}
+
+// objectHeaders 可以在上传时自定义的标准响应头
+var objectHeaders = []string{
+	"Cache-Control",
+	"Content-Disposition",
+	"Content-Encoding",
+	"Content-Language",
+	"Expires",
+}
+
+// parseHeader 从HEAD请求的响应头中解析对象元信息 vendor为服务商的响应头前缀 如 cos/oss/amz
+func parseHeader(info *ObjectInfo, header http.Header, vendor string) {
+	var metaPrefix = "x-" + vendor + "-meta-"
+	info.ContentType = header.Get("Content-Type")
+	info.ETag = strings.Trim(header.Get("ETag"), "\"")
+	info.StorageClass = header.Get("x-" + vendor + "-storage-class")
+	if size, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+		info.Size = size
+	}
+	if t, err := http.ParseTime(header.Get("Last-Modified")); err == nil {
+		info.LastModified = t
+	}
+	info.Headers = make(map[string]string)
+	for _, key := range objectHeaders {
+		if value := header.Get(key); value != "" {
+			info.Headers[key] = value
+		}
+	}
+	info.Metadata = make(map[string]string)
+	for key := range header {
+		if strings.HasPrefix(strings.ToLower(key), metaPrefix) {
+			info.Metadata[strings.ToLower(key)[len(metaPrefix):]] = header.Get(key)
+		}
+	}
+}
+
+// Key 对象的绝对路径
+func (o ListObject) Key() string {
+	return o.Prefix + o.Filename
+}
+
+// MD5 列举结果中的ETag为内容的MD5时返回小写的MD5 否则返回空字符串
+// 分片上传的对象与七牛云的ETag不是MD5 不能用于跨存储服务比较内容
+func (o ListObject) MD5() string {
+	var etag = strings.ToLower(strings.Trim(o.ETag, "\""))
+	if len(etag) != 32 {
+		return ""
+	}
+	if _, err := hex.DecodeString(etag); err != nil {
+		return ""
+	}
+	return etag
+}
+
+// Bytes 对象大小 各存储服务列举结果中的类型不同 统一转换为int64
+func (o ListObject) Bytes() int64 {
+	switch size := o.Size.(type) {
+	case int:
+		return int64(size)
+	case int64:
+		return size
+	case float64:
+		return int64(size)
+	}
+	return 0
+}
+
+// ModTime 对象的最后修改时间 Cos的列举结果为ISO8601格式的字符串
+func (o ListObject) ModTime() time.Time {
+	switch t := o.CreateTime.(type) {
+	case time.Time:
+		return t
+	case string:
+		modTime, _ := time.Parse(time.RFC3339, t)
+		return modTime
+	}
+	return time.Time{}
+}
+
+// Page 分页列举的结果
+type Page struct {
+	Objects []ListObject //当前页的文件与目录
+	Next    string       //下一页的游标 为空时表示已经列举完毕
+}
+
+// Storage 存储驱动 每个云存储服务都需要实现该接口
+type Storage interface {
+	// List 分页列举prefix目录下的文件与目录
+	// marker为上一页返回的游标 首页为空 limit为单页数量 为0时使用服务的默认值
+	List(prefix, marker string, limit int) (*Page, error)
+	// Delete 删除path对应的对象
+	Delete(path string) error
+	// Put 上传对象到key
+	Put(key string, reader io.Reader) error
+	// Mkdir 创建目录 path为目录的绝对路径
+	Mkdir(path string) error
+	// Get 读取对象内容 调用方负责关闭
+	Get(path string) (io.ReadCloser, error)
+	// Move 移动(重命名)单个对象 目标已存在时会被覆盖
+	Move(src, dst string) error
+	// Copy 在同一存储桶内复制单个对象 目标已存在时会被覆盖
+	Copy(src, dst string) error
+	// Stat 获取对象的元信息
+	Stat(path string) (*ObjectInfo, error)
+	// Sign 生成有效期为expire的临时下载地址 用于访问私有存储桶中的对象
+	Sign(path string, expire time.Duration) (string, error)
+	// Domain 对象的访问域名 以 / 结尾
+	Domain() string
+}
+
+// Driver 根据配置文件创建一个存储驱动
+type Driver func(conf *config.Config) (Storage, error)
+
+var (
+	// ErrUnknownDriver 未注册的存储驱动
+	ErrUnknownDriver = errors.New("unknown storage driver")
+
+	drivers = make(map[string]Driver)
+
+	// httpClient 直接调用REST API的驱动(又拍云/七牛云)使用的客户端 超时时间与Cos一致
+	httpClient = &http.Client{Timeout: 100 * time.Second}
+)
+
+// Register 注册存储驱动 name为存储服务类型 [Ups/Cos/Oss/S3/Qiniu/Local]
+func Register(name string, driver Driver) {
+	if driver == nil {
+		panic("storage: register driver is nil")
+	}
+	if _, ok := drivers[name]; ok {
+		panic("storage: register called twice for driver " + name)
+	}
+	drivers[name] = driver
+}
+
+// Drivers 已注册的存储服务类型
+func Drivers() []string {
+	var names = make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New 实例化name对应的存储驱动 name可以为存储服务类型或配置文件中命名的存储目标
+// 命名的存储目标重名或与存储服务类型重名时返回错误 避免静默覆盖
+func New(name string, conf *config.Config) (Storage, error) {
+	if err := conf.CheckTargets(Drivers()); err != nil {
+		return nil, err
+	}
+	if target, ok := conf.Target(name); ok {
+		name, conf = target.Type, conf.WithTarget(target)
+		//命名的本地存储目标默认由 /local/<名称>/ 提供访问 与Local.Root的 /local/ 区分
+		if name == "Local" && conf.Local.Domain == "" {
+			conf.Local.Domain = LocalTargetRoute(target.Name)
+		}
+	}
+	driver, ok := drivers[name]
+	if !ok {
+		return nil, ErrUnknownDriver
+	}
+	return driver(conf)
+}
+
+// ListFiles 递归列举prefix目录下的所有文件 不包含目录
+func ListFiles(store Storage, prefix string) ([]ListObject, error) {
+	objects, err := ListAll(store, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var files []ListObject
+	for _, obj := range objects {
+		//跳过mkdir创建的目录占位对象
+		if obj.Filename == "" || strings.HasSuffix(obj.Filename, "/") && !obj.IsDir {
+			continue
+		}
+		if !obj.IsDir {
+			files = append(files, obj)
+			continue
+		}
+		sub, err := ListFiles(store, obj.Key())
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, sub...)
+	}
+	return files, nil
+}
+
+// ListAll 列举prefix目录下的所有文件与目录
+func ListAll(store Storage, prefix string) ([]ListObject, error) {
+	var result []ListObject //结果集
+	var marker string
+	for {
+		page, err := store.List(prefix, marker, 0)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, page.Objects...)
+		if page.Next == "" {
+			return result, nil
+		}
+		marker = page.Next
+	}
+}
+
+// sizedReader 已知大小的读取器 跨存储服务复制时携带源对象的大小
+type sizedReader struct {
+	io.Reader
+	size int64
+}
+
+// readerSize 读取器剩余内容的大小 无法确定时返回-1
+// 上传的表单文件与本地文件可以通过Seek获取大小
+func readerSize(reader io.Reader) int64 {
+	switch r := reader.(type) {
+	case *sizedReader:
+		return r.size
+	case io.Seeker:
+		current, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		end, err := r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return -1
+		}
+		if _, err = r.Seek(current, io.SeekStart); err != nil {
+			return -1
+		}
+		return end - current
+	}
+	return -1
+}
@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestReaderSize(t *testing.T) {
+	var partial = strings.NewReader("0123456789")
+	_, _ = io.CopyN(ioutil.Discard, partial, 4)
+	var tests = []struct {
+		reader io.Reader
+		want   int64
+	}{
+		{strings.NewReader("0123456789"), 10},
+		{partial, 6},
+		{&sizedReader{Reader: strings.NewReader("abc"), size: 3}, 3},
+		{io.MultiReader(strings.NewReader("abc")), -1},
+	}
+	for i, test := range tests {
+		if got := readerSize(test.reader); got != test.want {
+			t.Errorf("case %d: readerSize = %d, want %d", i, got, test.want)
+		}
+	}
+	//获取大小后读取位置不变
+	if data, _ := ioutil.ReadAll(partial); string(data) != "456789" {
+		t.Errorf("read after readerSize = %q", data)
+	}
+}
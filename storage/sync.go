@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultSyncConcurrency 同步时默认的并发数
+	DefaultSyncConcurrency = 4
+	// MaxSyncConcurrency 同步时并发数的上限
+	MaxSyncConcurrency = 16
+)
+
+// SyncOptions 同步选项
+type SyncOptions struct {
+	DryRun      bool //只比较差异 不复制或删除对象
+	Delete      bool //删除目标目录中源目录不存在的对象
+	Concurrency int  //同时复制或删除的对象数量
+}
+
+// SyncReport 同步结果 路径均为目标存储服务中的绝对路径
+type SyncReport struct {
+	DryRun  bool              `json:"dry_run"` //是否为试运行
+	Copied  []string          `json:"copied"`  //复制(试运行时为需要复制)的对象
+	Deleted []string          `json:"deleted"` //删除(试运行时为需要删除)的对象
+	Skipped int               `json:"skipped"` //内容一致无需复制的对象数量
+	Reasons map[string]string `json:"reasons"` //需要复制的原因 new/size/hash/mtime
+	Failed  []Failure         `json:"failed"`  //复制或删除失败的对象
+
+	mu sync.Mutex
+}
+
+// syncTask 同步任务中需要复制或删除的对象
+type syncTask struct {
+	src, dst string
+	size     int64 //源对象的大小 跨存储服务复制时使用
+	remove   bool
+}
+
+// Sync 将from中的src目录同步到to中的dst目录 两者均以 / 结尾或为空
+// 按相对路径比较 源对象不存在于目标、大小不同、MD5不同或比目标更新时复制
+// 同一存储服务内使用服务端复制 跨存储服务时以流的方式中转
+func Sync(from, to Storage, src, dst string, opt SyncOptions) (*SyncReport, error) {
+	srcFiles, err := ListFiles(from, src)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := relativeFiles(to, dst)
+	if err != nil {
+		return nil, err
+	}
+	var report = &SyncReport{DryRun: opt.DryRun, Reasons: make(map[string]string)}
+	var tasks []syncTask
+	for _, obj := range srcFiles {
+		var name = strings.TrimPrefix(obj.Key(), src)
+		target, ok := existing[name]
+		delete(existing, name)
+		var reason = syncReason(obj, target, ok)
+		if reason == "" {
+			report.Skipped++
+			continue
+		}
+		report.Reasons[dst+name] = reason
+		tasks = append(tasks, syncTask{src: obj.Key(), dst: dst + name, size: obj.Bytes()})
+	}
+	if opt.Delete {
+		for name := range existing {
+			tasks = append(tasks, syncTask{dst: dst + name, remove: true})
+		}
+	}
+	if opt.DryRun {
+		for _, task := range tasks {
+			report.done(task)
+		}
+		return report, nil
+	}
+	var concurrency = opt.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultSyncConcurrency
+	}
+	if concurrency > MaxSyncConcurrency {
+		concurrency = MaxSyncConcurrency
+	}
+	var wg sync.WaitGroup
+	var queue = make(chan syncTask)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range queue {
+				var err error
+				switch {
+				case task.remove:
+					err = to.Delete(task.dst)
+				case from == to:
+					err = to.Copy(task.src, task.dst)
+				default:
+					err = transfer(from, to, task.src, task.dst, task.size)
+				}
+				if err != nil {
+					report.fail(task.dst, err)
+				} else {
+					report.done(task)
+				}
+			}
+		}()
+	}
+	for _, task := range tasks {
+		queue <- task
+	}
+	close(queue)
+	wg.Wait()
+	return report, nil
+}
+
+// syncReason 源对象需要复制到目标的原因 无需复制时返回空字符串
+func syncReason(src, dst ListObject, exists bool) string {
+	switch {
+	case !exists:
+		return "new"
+	case src.Bytes() != dst.Bytes():
+		return "size"
+	case src.MD5() != "" && dst.MD5() != "":
+		if src.MD5() != dst.MD5() {
+			return "hash"
+		}
+		return ""
+	case src.ModTime().After(dst.ModTime()):
+		//无法比较内容时 源对象比目标更新才复制
+		return "mtime"
+	}
+	return ""
+}
+
+// done 记录完成的任务
+func (s *SyncReport) done(task syncTask) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if task.remove {
+		s.Deleted = append(s.Deleted, task.dst)
+	} else {
+		s.Copied = append(s.Copied, task.dst)
+	}
+}
+
+// fail 记录失败的任务
+func (s *SyncReport) fail(key string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Failed = append(s.Failed, Failure{Key: key, Error: err.Error()})
+}
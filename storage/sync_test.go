@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSyncReason(t *testing.T) {
+	var now = time.Now()
+	var md5a = "0cc175b9c0f1b6a831c399e269772661"
+	var md5b = "92eb5ffee6ae2fec3ad71c777531578f"
+	var tests = []struct {
+		src, dst ListObject
+		exists   bool
+		want     string
+	}{
+		{ListObject{Size: int64(1)}, ListObject{}, false, "new"},
+		{ListObject{Size: int64(1)}, ListObject{Size: int64(2)}, true, "size"},
+		{ListObject{Size: int64(1), ETag: md5a}, ListObject{Size: int64(1), ETag: "\"" + md5b + "\""}, true, "hash"},
+		{ListObject{Size: int64(1), ETag: md5a, CreateTime: now}, ListObject{Size: int64(1), ETag: md5a}, true, ""},
+		{ListObject{Size: int64(1), CreateTime: now}, ListObject{Size: int64(1), CreateTime: now.Add(-time.Hour)}, true, "mtime"},
+		{ListObject{Size: int64(1), CreateTime: now}, ListObject{Size: int64(1), CreateTime: now.Add(time.Hour)}, true, ""},
+		//七牛云的ETag不是MD5 按修改时间比较
+		{ListObject{Size: 1.0, ETag: "FmDZwqadA4-ib_15hYfQpb7UXUYR", CreateTime: now}, ListObject{Size: int64(1), ETag: md5a}, true, "mtime"},
+	}
+	for i, test := range tests {
+		if got := syncReason(test.src, test.dst, test.exists); got != test.want {
+			t.Errorf("case %d: syncReason = %q, want %q", i, got, test.want)
+		}
+	}
+}
+
+func TestSyncRoot(t *testing.T) {
+	from, cleanFrom := newTestLocal(t)
+	defer cleanFrom()
+	to, cleanTo := newTestLocal(t)
+	defer cleanTo()
+	putTestFiles(t, from, map[string]string{"a.txt": "a", "img/b.png": "b"})
+	//目标中的文件更新且大小相同 无需复制
+	putTestFiles(t, to, map[string]string{"img/b.png": "b", "stale.txt": "s"})
+
+	report, err := Sync(from, to, "", "", SyncOptions{DryRun: true, Delete: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.Copied, []string{"a.txt"}) || !reflect.DeepEqual(report.Deleted, []string{"stale.txt"}) || report.Skipped != 1 {
+		t.Errorf("dry run: copied %v deleted %v skipped %d", report.Copied, report.Deleted, report.Skipped)
+	}
+	if report.Reasons["a.txt"] != "new" {
+		t.Errorf("dry run reasons = %v", report.Reasons)
+	}
+	if _, err = to.Stat("a.txt"); err == nil {
+		t.Error("dry run copied a.txt")
+	}
+	if _, err = to.Stat("stale.txt"); err != nil {
+		t.Error("dry run deleted stale.txt")
+	}
+
+	if report, err = Sync(from, to, "", "", SyncOptions{Delete: true}); err != nil || len(report.Failed) > 0 {
+		t.Fatal(err, report.Failed)
+	}
+	files, err := relativeFiles(to, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"a.txt", "img/b.png"}) {
+		t.Errorf("after sync = %v", names)
+	}
+
+	if report, err = Sync(from, to, "", "", SyncOptions{DryRun: true, Delete: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Copied) != 0 || len(report.Deleted) != 0 || report.Skipped != 2 {
+		t.Errorf("second sync: copied %v deleted %v skipped %d", report.Copied, report.Deleted, report.Skipped)
+	}
+}
+
+func TestSyncPrefix(t *testing.T) {
+	store, cleanup := newTestLocal(t)
+	defer cleanup()
+	putTestFiles(t, store, map[string]string{"photos/a.png": "a", "photos/2020/b.png": "b", "other.txt": "o"})
+	report, err := Sync(store, store, "photos/", "backup/", SyncOptions{})
+	if err != nil || len(report.Failed) > 0 {
+		t.Fatal(err, report.Failed)
+	}
+	sort.Strings(report.Copied)
+	if !reflect.DeepEqual(report.Copied, []string{"backup/2020/b.png", "backup/a.png"}) {
+		t.Errorf("copied = %v", report.Copied)
+	}
+	if _, err = store.Stat("backup/other.txt"); err == nil {
+		t.Error("file outside the prefix was copied")
+	}
+}
@@ -0,0 +1,328 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/upyun/go-sdk/upyun"
+
+	"Pines/config"
+)
+
+const (
+	// upsAPIHost 又拍云REST API地址
+	upsAPIHost = "v0.api.upyun.com"
+	// upsListLimit 又拍云单次列举的默认数量
+	upsListLimit = 1000
+	// upsListEOF 又拍云列举结束时返回的游标
+	upsListEOF = "g2gCZAAEbmV4dGQAA2VvZg"
+)
+
+// Ups 又拍云Ups服务
+type Ups struct {
+	conf config.Ups
+	up   *upyun.UpYun
+}
+
+func init() {
+	Register("Ups", NewUps)
+}
+
+// NewUps 初始化又拍云客户端
+func NewUps(conf *config.Config) (Storage, error) {
+	var up = upyun.NewUpYun(&upyun.UpYunConfig{
+		Bucket:   conf.Ups.Bucket,
+		Operator: conf.Ups.Operator,
+		Password: conf.Ups.Password,
+	})
+	return &Ups{conf: conf.Ups, up: up}, nil
+}
+
+// List 分页列举当前目录下的文件 游标为又拍云的X-List-Iter
+// 只在请求地址中补全结尾的 / 列举结果的Prefix与其他存储服务一致 保持调用方传入的值 根目录为空
+func (u *Ups) List(prefix, marker string, limit int) (*Page, error) {
+	var dir = strings.TrimSuffix(prefix, "/") + "/"
+	var headers = map[string]string{
+		"Accept":         "application/json",
+		"X-UpYun-Folder": "true",
+		"X-List-Limit":   strconv.Itoa(upsListLimit),
+	}
+	if limit > 0 {
+		headers["X-List-Limit"] = strconv.Itoa(limit)
+	}
+	if marker != "" {
+		headers["X-List-Iter"] = marker
+	}
+	resp, err := u.request(http.MethodGet, dir, headers, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Files []struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+			Size int64  `json:"length"`
+			Time int64  `json:"last_modified"`
+		} `json:"files"`
+		Iter string `json:"iter"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	var page = new(Page)
+	for _, obj := range body.Files {
+		var isDir = obj.Type == "folder"
+		var filename = obj.Name
+		if isDir {
+			filename += "/"
+		}
+		page.Objects = append(page.Objects, ListObject{
+			Filename:   filename,
+			Prefix:     prefix,
+			IsDir:      isDir,
+			Size:       obj.Size,
+			CreateTime: time.Unix(obj.Time, 0),
+		})
+	}
+	if body.Iter != upsListEOF {
+		page.Next = body.Iter
+	}
+	return page, nil
+}
+
+// Delete 删除对象
+func (u *Ups) Delete(path string) error {
+	return u.up.Delete(&upyun.DeleteObjectConfig{
+		Path:  path,
+		Async: false,
+	})
+}
+
+// Put 上传对象
+func (u *Ups) Put(key string, reader io.Reader) error {
+	return u.up.Put(&upyun.PutObjectConfig{
+		Path:   key,
+		Reader: reader,
+	})
+}
+
+// Mkdir 创建目录
+func (u *Ups) Mkdir(path string) error {
+	return u.up.Mkdir(path)
+}
+
+// Get 读取对象内容
+func (u *Ups) Get(path string) (io.ReadCloser, error) {
+	return u.GetRange(path, 0)
+}
+
+// GetRange 从offset处开始读取对象内容
+func (u *Ups) GetRange(path string, offset int64) (io.ReadCloser, error) {
+	var headers map[string]string
+	if offset > 0 {
+		headers = map[string]string{"Range": fmt.Sprintf("bytes=%d-", offset)}
+	}
+	resp, err := u.request(http.MethodGet, path, headers, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Move 移动对象 使用又拍云的移动接口
+func (u *Ups) Move(src, dst string) error {
+	resp, err := u.request(http.MethodPut, dst, map[string]string{
+		"X-Upyun-Move-Source": u.source(src),
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Copy 复制对象 使用又拍云的复制接口
+func (u *Ups) Copy(src, dst string) error {
+	resp, err := u.request(http.MethodPut, dst, map[string]string{
+		"X-Upyun-Copy-Source": u.source(src),
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Stat 获取对象元信息
+func (u *Ups) Stat(path string) (*ObjectInfo, error) {
+	resp, err := u.request(http.MethodHead, path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	info := &ObjectInfo{Key: path}
+	parseHeader(info, resp.Header, "upyun")
+	//又拍云的文件信息由专有响应头返回
+	info.IsDir = resp.Header.Get("x-upyun-file-type") == "folder"
+	info.Size, _ = strconv.ParseInt(resp.Header.Get("x-upyun-file-size"), 10, 64)
+	if date, err := strconv.ParseInt(resp.Header.Get("x-upyun-file-date"), 10, 64); err == nil {
+		info.LastModified = time.Unix(date, 0)
+	}
+	if info.ETag == "" {
+		info.ETag = resp.Header.Get("Content-Md5")
+	}
+	return info, nil
+}
+
+// Sign 生成Token防盗链的下载地址 未配置防盗链密钥时返回普通地址
+func (u *Ups) Sign(path string, expire time.Duration) (string, error) {
+	var uri = (&url.URL{Path: "/" + strings.TrimPrefix(path, "/")}).EscapedPath()
+	var link = strings.TrimSuffix(u.conf.Domain, "/") + uri
+	if u.conf.Secret == "" {
+		return link, nil
+	}
+	var etime = strconv.FormatInt(time.Now().Add(expire).Unix(), 10)
+	var sum = md5.Sum([]byte(u.conf.Secret + "&" + etime + "&" + uri))
+	return link + "?_upt=" + hex.EncodeToString(sum[:])[12:20] + etime, nil
+}
+
+// PresignUpload 签发表单API使用的Policy与签名
+func (u *Ups) PresignUpload(key string, expire time.Duration) (*UploadPolicy, error) {
+	var deadline = time.Now().Add(expire)
+	policy, err := json.Marshal(map[string]interface{}{
+		"bucket":     u.up.Bucket,
+		"save-key":   "/" + strings.TrimPrefix(key, "/"),
+		"expiration": deadline.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var encoded = base64.StdEncoding.EncodeToString(policy)
+	return &UploadPolicy{
+		Method: http.MethodPost,
+		URL:    "https://" + upsAPIHost + "/" + u.up.Bucket,
+		Key:    key,
+		Fields: map[string]string{
+			"policy": encoded,
+			"authorization": u.up.MakeUnifiedAuth(&upyun.UnifiedAuthConfig{
+				Method: http.MethodPost,
+				Uri:    "/" + u.up.Bucket,
+				Policy: encoded,
+			}),
+		},
+		FileField: "file",
+		Expire:    deadline,
+	}, nil
+}
+
+// InitiateMultipart 初始化并行式断点续传 分片可以乱序上传
+func (u *Ups) InitiateMultipart(key string) (string, error) {
+	resp, err := u.request(http.MethodPut, key, map[string]string{
+		"X-Upyun-Multi-Disorder": "true",
+		"X-Upyun-Multi-Stage":    "initiate",
+		"X-Upyun-Multi-Type":     "application/octet-stream",
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	_ = resp.Body.Close()
+	return resp.Header.Get("X-Upyun-Multi-Uuid"), nil
+}
+
+// UploadPart 上传分片 又拍云的分片编号从0开始 除最后一个分片外大小需要为1M的整数倍
+func (u *Ups) UploadPart(key, uploadID string, number int, reader io.Reader, size int64) (*Part, error) {
+	//分片接口需要Content-Length 分片内容读入内存后再上传
+	if size > maxBufferedPartSize {
+		return nil, ErrPartTooLarge
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(reader, size))
+	if err != nil {
+		return nil, err
+	}
+	var sum = md5.Sum(data)
+	resp, err := u.request(http.MethodPut, key, map[string]string{
+		"X-Upyun-Multi-Stage": "upload",
+		"X-Upyun-Multi-Uuid":  uploadID,
+		"X-Upyun-Part-Id":     strconv.Itoa(number - 1),
+	}, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	return &Part{Number: number, ETag: hex.EncodeToString(sum[:]), Size: int64(len(data))}, nil
+}
+
+// ListParts 又拍云没有列举已上传分片的接口 需要客户端自行记录
+func (u *Ups) ListParts(key, uploadID string) ([]Part, error) {
+	return nil, ErrNotSupported
+}
+
+// CompleteMultipart 完成分片上传 又拍云按分片编号合并所有已上传的分片
+func (u *Ups) CompleteMultipart(key, uploadID string, parts []Part) error {
+	resp, err := u.request(http.MethodPut, key, map[string]string{
+		"X-Upyun-Multi-Stage": "complete",
+		"X-Upyun-Multi-Uuid":  uploadID,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// AbortMultipart 又拍云没有取消接口 未完成的分片上传会在24小时后自动清理
+func (u *Ups) AbortMultipart(key, uploadID string) error {
+	return ErrNotSupported
+}
+
+// Domain 访问域名
+func (u *Ups) Domain() string {
+	return u.conf.Domain
+}
+
+// source 移动与复制接口使用的源路径 规则 /<bucket>/<path>
+func (u *Ups) source(key string) string {
+	return (&url.URL{Path: path.Join("/", u.up.Bucket, key)}).EscapedPath()
+}
+
+// request 发送签名后的REST请求 用于SDK未提供的接口
+func (u *Ups) request(method, uri string, headers map[string]string, body io.Reader) (*http.Response, error) {
+	var escURI = (&url.URL{Path: path.Join("/", u.up.Bucket, uri)}).EscapedPath()
+	if strings.HasSuffix(uri, "/") {
+		escURI += "/"
+	}
+	req, err := http.NewRequest(method, "https://"+upsAPIHost+escURI, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("Authorization", u.up.MakeUnifiedAuth(&upyun.UnifiedAuthConfig{
+		Method:     method,
+		Uri:        escURI,
+		DateStr:    req.Header.Get("Date"),
+		ContentMD5: req.Header.Get("Content-MD5"),
+	}))
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("%s %d %s", method, resp.StatusCode, string(msg))
+	}
+	return resp, nil
+}